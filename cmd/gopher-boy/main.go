@@ -3,16 +3,28 @@
 package main
 
 import (
+	"bufio"
 	"errors"
+	"flag"
+	"fmt"
 	"log"
 	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
 
+	padif "github.com/bokuweb/gopher-boy/pkg/interfaces/pad"
 	"github.com/bokuweb/gopher-boy/pkg/interrupt"
 	"github.com/bokuweb/gopher-boy/pkg/pad"
+	"github.com/bokuweb/gopher-boy/pkg/sgb"
 
 	"github.com/bokuweb/gopher-boy/pkg/gpu"
 	"github.com/bokuweb/gopher-boy/pkg/timer"
+	"github.com/bokuweb/gopher-boy/pkg/types"
 	"github.com/bokuweb/gopher-boy/pkg/utils"
+	"github.com/bokuweb/gopher-boy/pkg/viewer"
 
 	"github.com/bokuweb/gopher-boy/pkg/cpu"
 	"github.com/bokuweb/gopher-boy/pkg/gb"
@@ -30,15 +42,38 @@ func main() {
 		level = os.Getenv("LEVEL")
 	}
 	l := logger.NewLogger(logger.LogLevel(level))
-	if len(os.Args) != 2 {
+	tracePath := flag.String("trace", "", "write gameboy-doctor style instruction trace to the file")
+	traceLimit := flag.Uint("trace-limit", 0, "stop tracing after N instructions (0 means unlimited)")
+	tracePC := flag.String("trace-pc", "", "trace only instructions whose PC is in the hex range from:to, such as 0100:7FFF")
+	bios := flag.String("bios", "", "boot ROM file (DMG/MGB/SGB/CGB), boot is skipped if not specified")
+	hleBoot := flag.Bool("hle-boot", false, "emulate boot logo scroll without boot ROM")
+	hleStrict := flag.Bool("hle-strict", false, "lock up on invalid logo or header checksum as the hardware does")
+	warnLocked := flag.Bool("warn-locked-access", false, "log VRAM and OAM accesses blocked by PPU mode")
+	paletteName := flag.String("palette", "dmg", "palette preset ("+strings.Join(gpu.PalettePresetNames(), ", ")+") or JSON/JASC-PAL palette file")
+	colorize := flag.Bool("colorize", false, "colourise DMG games by title as CGB boot ROM does, -palette is used for unknown titles")
+	modelName := flag.String("model", "", "hardware model (DMG0, DMG, MGB, SGB, SGB2, CGB or AGB), SGB and SGB2 show the border, CGB games run on CGB and others on DMG if not specified")
+	dumpDir := flag.String("dump-dir", "vram", "directory F12 dumps tile sheet, BG maps, window map and OAM to as PNG")
+	flag.Parse()
+	if flag.NArg() != 1 {
 		log.Fatalf("ERROR: %v", errors.New("Please specify the ROM"))
 	}
-	file := os.Args[1]
+	model, ok := gb.ParseModel(*modelName)
+	if !ok && *modelName != "" {
+		log.Fatalf("ERROR: unknown model %q", *modelName)
+	}
+	palettes, err := loadPalettes(*paletteName)
+	if err != nil {
+		log.Fatalf("ERROR: %v", err)
+	}
+	file := flag.Arg(0)
 	log.Println(file)
 	buf, err := utils.LoadROM(file)
 	if err != nil {
 		log.Fatalf("ERROR: %v", errors.New("Failed to load ROM"))
 	}
+	if *modelName == "" {
+		model = gb.ROMModel(buf)
+	}
 	cart, err := cartridge.NewCartridge(buf)
 	if err != nil {
 		log.Fatalf("ERROR: %v", errors.New("Failed to create cartridge"))
@@ -51,12 +86,117 @@ func main() {
 	t := timer.NewTimer()
 	pad := pad.NewPad()
 	irq := interrupt.NewInterrupt()
-	b := bus.NewBus(l, cart, gpu, vRAM, wRAM, hRAM, oamRAM, t, irq, pad)
+	var joypad padif.Pad = pad
+	var s *sgb.SGB
+	if model == gb.SGB || model == gb.SGB2 {
+		s = sgb.NewSGB(pad)
+		joypad = s
+	}
+	b := bus.NewBus(l, cart, gpu, vRAM, wRAM, hRAM, oamRAM, t, irq, joypad)
+	if *warnLocked {
+		b.SetAccessCheck(bus.AccessWarn)
+	}
 	gpu.Init(b, irq)
+	gpu.SetPalettes(palettes)
 	win := window.NewWindow(pad)
-	emu := gb.NewGB(cpu.NewCPU(l, b, irq), gpu, t, irq, win)
+	if s != nil {
+		win.SetScreenSize(sgb.Width, sgb.Height)
+	}
+	v := viewer.NewViewer(b, gpu)
+	win.SetDumpHandler(func() {
+		if err := v.Dump(*dumpDir); err != nil {
+			log.Printf("WARNING: %v", err)
+			return
+		}
+		log.Printf("VRAM is dumped to %s", *dumpDir)
+	})
+	c := cpu.NewCPU(l, b, irq)
+	if *tracePath != "" {
+		f, err := os.Create(*tracePath)
+		if err != nil {
+			log.Fatalf("ERROR: %v", err)
+		}
+		tracer := cpu.NewTracer(bufio.NewWriter(f))
+		tracer.SetLimit(*traceLimit)
+		if *tracePC != "" {
+			from, to, err := parsePCRange(*tracePC)
+			if err != nil {
+				log.Fatalf("ERROR: %v", err)
+			}
+			tracer.SetPCRange(from, to)
+		}
+		c.SetTracer(tracer)
+		// The trace is closed when the window is closed or the process is stopped.
+		var once sync.Once
+		closeTrace := func() {
+			if err := tracer.Flush(); err != nil {
+				log.Printf("WARNING: %v", err)
+			}
+			if err := f.Close(); err != nil {
+				log.Printf("WARNING: %v", err)
+			}
+		}
+		defer once.Do(closeTrace)
+		sig := make(chan os.Signal, 1)
+		signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
+		go func() {
+			<-sig
+			once.Do(closeTrace)
+			os.Exit(1)
+		}()
+	}
+	opts := []gb.Option{gb.WithModel(model)}
+	if *colorize {
+		opts = append(opts, gb.WithColorize(palettes))
+	}
+	if s != nil {
+		opts = append(opts, gb.WithSGB(s))
+	}
+	emu := gb.NewGB(b, c, gpu, t, irq, win, opts...)
+	if *bios != "" {
+		boot, err := utils.LoadROM(*bios)
+		if err != nil {
+			log.Fatalf("ERROR: %v", err)
+		}
+		if err := emu.LoadBootROM(boot); err != nil {
+			log.Fatalf("ERROR: %v", err)
+		}
+	} else if *hleBoot {
+		if err := emu.BootHLE(*hleStrict); err != nil {
+			log.Printf("WARNING: %v", err)
+		}
+	}
 	win.Run(func() {
 		win.Init()
 		emu.Start()
 	})
 }
+
+// parsePCRange parses hex PC range such as "0100:7FFF".
+func parsePCRange(s string) (types.Word, types.Word, error) {
+	r := strings.Split(s, ":")
+	if len(r) != 2 {
+		return 0, 0, fmt.Errorf("invalid PC range %q", s)
+	}
+	from, err := strconv.ParseUint(r[0], 16, 16)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid PC range %q", s)
+	}
+	to, err := strconv.ParseUint(r[1], 16, 16)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid PC range %q", s)
+	}
+	return types.Word(from), types.Word(to), nil
+}
+
+// loadPalettes returns the preset of the name or palettes in the file.
+func loadPalettes(name string) (gpu.Palettes, error) {
+	if p, ok := gpu.PalettePreset(name); ok {
+		return p, nil
+	}
+	data, err := utils.LoadROM(name)
+	if err != nil {
+		return gpu.Palettes{}, err
+	}
+	return gpu.ParsePalettes(data)
+}
@@ -9,15 +9,18 @@ import (
 	"log"
 	"syscall/js"
 
+	padif "github.com/bokuweb/gopher-boy/pkg/interfaces/pad"
 	"github.com/bokuweb/gopher-boy/pkg/interrupt"
 	"github.com/bokuweb/gopher-boy/pkg/logger"
 	"github.com/bokuweb/gopher-boy/pkg/pad"
+	"github.com/bokuweb/gopher-boy/pkg/sgb"
 	"github.com/bokuweb/gopher-boy/pkg/types"
 	"github.com/bokuweb/gopher-boy/pkg/window"
 
 	"github.com/bokuweb/gopher-boy/pkg/gpu"
 	"github.com/bokuweb/gopher-boy/pkg/timer"
 
+	"github.com/bokuweb/gopher-boy/pkg/constants"
 	"github.com/bokuweb/gopher-boy/pkg/cpu"
 	"github.com/bokuweb/gopher-boy/pkg/gb"
 	"github.com/bokuweb/gopher-boy/pkg/ram"
@@ -44,16 +47,63 @@ func newGB(this js.Value, args []js.Value) interface{} {
 	t := timer.NewTimer()
 	pad := pad.NewPad()
 	irq := interrupt.NewInterrupt()
-	b := bus.NewBus(l, cart, gpu, vRAM, wRAM, hRAM, oamRAM, t, irq, pad)
+	// Optional model name such as "SGB", the model of the cartridge if not specified
+	model := gb.ROMModel(buf)
+	if len(args) > 2 && args[2].Type() == js.TypeString {
+		m, ok := gb.ParseModel(args[2].String())
+		if !ok {
+			log.Fatalf("ERROR: unknown model %s", args[2].String())
+		}
+		model = m
+	}
+	var joypad padif.Pad = pad
+	var s *sgb.SGB
+	width, height := constants.ScreenWidth, constants.ScreenHeight
+	opts := []gb.Option{gb.WithModel(model)}
+	if model == gb.SGB || model == gb.SGB2 {
+		s = sgb.NewSGB(pad)
+		joypad = s
+		width, height = sgb.Width, sgb.Height
+		opts = append(opts, gb.WithSGB(s))
+	}
+	b := bus.NewBus(l, cart, gpu, vRAM, wRAM, hRAM, oamRAM, t, irq, joypad)
 	gpu.Init(b, irq)
 
 	win := window.NewWindow(pad)
-	emu := gb.NewGB(cpu.NewCPU(l, b, irq), gpu, t, irq, win)
+	emu := gb.NewGB(b, cpu.NewCPU(l, b, irq), gpu, t, irq, win, opts...)
+	// Optional boot ROM
+	if len(args) > 1 && args[1].Type() == js.TypeObject {
+		boot := make([]byte, args[1].Get("length").Int())
+		js.CopyBytesToGo(boot, args[1])
+		if err := emu.LoadBootROM(boot); err != nil {
+			log.Fatalf("ERROR: %v", err)
+		}
+	}
 
 	this.Set("next", js.FuncOf(func(this js.Value, args []js.Value) interface{} {
 		img := emu.Next()
 		return js.CopyBytesToJS(args[0], img)
 	}))
+	// getScreenSize returns [width, height] of images of next, 256x224 with the border on SGB.
+	this.Set("getScreenSize", js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+		return []interface{}{width, height}
+	}))
+	this.Set("isScreenBlank", js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+		return emu.ScreenBlank()
+	}))
+	// setPalette takes a preset name or JSON/JASC-PAL palette text and returns error message or null.
+	this.Set("setPalette", js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+		p, err := parsePalettes(args[0].String())
+		if err != nil {
+			return err.Error()
+		}
+		gpu.SetPalettes(p)
+		return nil
+	}))
+	// getPalettes copies RGBA of 4 shades of BG, OBP0 and OBP1 in this order.
+	this.Set("getPalettes", js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+		return js.CopyBytesToJS(args[0], paletteBytes(gpu.Palettes()))
+	}))
 	this.Set("keyDown", js.FuncOf(func(this js.Value, args []js.Value) interface{} {
 		win.KeyDown(byte(args[0].Int()))
 		return nil
@@ -74,9 +124,60 @@ func newGB(this js.Value, args []js.Value) interface{} {
 	this.Set("readGPU", js.FuncOf(func(this js.Value, args []js.Value) interface{} {
 		return gpu.Read(types.Word(args[0].Int()))
 	}))
+	// Layers are 1 (BG), 2 (window) and 4 (sprites), and can be ORed.
+	this.Set("setLayerVisible", js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+		gpu.SetLayerVisible(layer(args[0]), args[1].Bool())
+		return nil
+	}))
+	this.Set("enableLayerBuffers", js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+		gpu.EnableLayerBuffers(args[0].Bool())
+		return nil
+	}))
+	// getLayerImage copies RGBA image of a layer, pixels the layer does not draw are transparent.
+	this.Set("getLayerImage", js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+		img := gpu.LayerImage(layer(args[0]))
+		if img == nil {
+			return 0
+		}
+		return js.CopyBytesToJS(args[1], img)
+	}))
+	// getSources copies layer and OAM index of the sprite for each pixel, 2 bytes per pixel.
+	this.Set("getSources", js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+		return js.CopyBytesToJS(args[0], sourceBytes(gpu.Sources()))
+	}))
 	return this
 }
 
+// parsePalettes returns the preset of the name or palettes parsed from the text.
+func parsePalettes(s string) (gpu.Palettes, error) {
+	if p, ok := gpu.PalettePreset(s); ok {
+		return p, nil
+	}
+	return gpu.ParsePalettes([]byte(s))
+}
+
+func paletteBytes(p gpu.Palettes) []byte {
+	buf := make([]byte, 0, 48)
+	for _, palette := range []gpu.Palette{p.BG, p.OBP0, p.OBP1} {
+		for _, c := range palette {
+			buf = append(buf, c.R, c.G, c.B, c.A)
+		}
+	}
+	return buf
+}
+
+func layer(v js.Value) gpu.Layer {
+	return gpu.Layer(v.Int())
+}
+
+func sourceBytes(sources []gpu.Source) []byte {
+	buf := make([]byte, 0, len(sources)*2)
+	for _, s := range sources {
+		buf = append(buf, byte(s.Layer), s.Sprite)
+	}
+	return buf
+}
+
 func main() {
 	w := js.Global()
 	w.Set("GB", js.FuncOf(newGB))
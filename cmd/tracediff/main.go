@@ -0,0 +1,43 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/bokuweb/gopher-boy/pkg/trace"
+)
+
+// tracediff reports the first line where two gameboy-doctor style traces disagree.
+//
+//	tracediff -context 10 gopher-boy.log reference.log
+func main() {
+	context := flag.Int("context", 5, "number of preceding lines to show")
+	flag.Parse()
+	if flag.NArg() != 2 {
+		log.Fatalf("Usage: tracediff [-context N] GOT_TRACE WANT_TRACE")
+	}
+	got, err := os.Open(flag.Arg(0))
+	if err != nil {
+		log.Fatalf("ERROR: %v", err)
+	}
+	defer got.Close()
+	want, err := os.Open(flag.Arg(1))
+	if err != nil {
+		log.Fatalf("ERROR: %v", err)
+	}
+	defer want.Close()
+
+	d, err := trace.Diff(got, want, *context)
+	if err != nil {
+		log.Fatalf("ERROR: %v", err)
+	}
+	if d == nil {
+		fmt.Println("traces are identical")
+		return
+	}
+	fmt.Printf("diverged at line %d\n", d.Line)
+	fmt.Print(d)
+	os.Exit(1)
+}
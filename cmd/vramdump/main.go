@@ -0,0 +1,70 @@
+package main
+
+import (
+	"flag"
+	"log"
+
+	"github.com/bokuweb/gopher-boy/pkg/bus"
+	"github.com/bokuweb/gopher-boy/pkg/cartridge"
+	"github.com/bokuweb/gopher-boy/pkg/cpu"
+	"github.com/bokuweb/gopher-boy/pkg/gb"
+	"github.com/bokuweb/gopher-boy/pkg/gpu"
+	"github.com/bokuweb/gopher-boy/pkg/interrupt"
+	"github.com/bokuweb/gopher-boy/pkg/logger"
+	"github.com/bokuweb/gopher-boy/pkg/pad"
+	"github.com/bokuweb/gopher-boy/pkg/ram"
+	"github.com/bokuweb/gopher-boy/pkg/timer"
+	"github.com/bokuweb/gopher-boy/pkg/utils"
+	"github.com/bokuweb/gopher-boy/pkg/viewer"
+)
+
+// headless is a window which shows nothing.
+type headless struct{}
+
+func (headless) Render(imageData []byte) {}
+func (headless) Run(run func())          { run() }
+func (headless) PollKey()                {}
+func (headless) KeyDown(button byte)     {}
+func (headless) KeyUp(button byte)       {}
+func (headless) Closed() bool            { return false }
+
+// vramdump runs the ROM without a window and dumps VRAM viewers as PNG.
+//
+//	vramdump -frames 300 -out vram YOUR_GAMEBOY_ROM.gb
+func main() {
+	frames := flag.Int("frames", 60, "number of frames to run before dumping")
+	out := flag.String("out", "vram", "output directory")
+	modelName := flag.String("model", "", "hardware model (DMG0, DMG, MGB, SGB, SGB2, CGB or AGB), CGB games run on CGB and others on DMG if not specified")
+	flag.Parse()
+	if flag.NArg() != 1 {
+		log.Fatalf("Usage: vramdump [-frames N] [-out DIR] [-model MODEL] ROM")
+	}
+	model, ok := gb.ParseModel(*modelName)
+	if !ok && *modelName != "" {
+		log.Fatalf("ERROR: unknown model %q", *modelName)
+	}
+	buf, err := utils.LoadROM(flag.Arg(0))
+	if err != nil {
+		log.Fatalf("ERROR: %v", err)
+	}
+	if *modelName == "" {
+		model = gb.ROMModel(buf)
+	}
+	cart, err := cartridge.NewCartridge(buf)
+	if err != nil {
+		log.Fatalf("ERROR: %v", err)
+	}
+	l := logger.NewLogger(logger.LogLevel("INFO"))
+	g := gpu.NewGPU()
+	t := timer.NewTimer()
+	irq := interrupt.NewInterrupt()
+	b := bus.NewBus(l, cart, g, ram.NewRAM(0x2000), ram.NewRAM(0x2000), ram.NewRAM(0x80), ram.NewRAM(0xA0), t, irq, pad.NewPad())
+	g.Init(b, irq)
+	emu := gb.NewGB(b, cpu.NewCPU(l, b, irq), g, t, irq, headless{}, gb.WithModel(model))
+	for i := 0; i < *frames; i++ {
+		emu.Next()
+	}
+	if err := viewer.NewViewer(b, g).Dump(*out); err != nil {
+		log.Fatalf("ERROR: %v", err)
+	}
+}
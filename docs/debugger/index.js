@@ -1,15 +1,8 @@
-const getPalette = c => {
-  switch (c) {
-    case 0:
-      return [175, 197, 160, 255];
-    case 1:
-      return [93, 147, 66, 255];
-    case 2:
-      return [22, 63, 48, 255];
-    case 3:
-      return [0, 40, 0, 255];
-  }
-};
+// RGBA of 4 shades of BG, OBP0 and OBP1, copied from the emulator every frame.
+const palettes = new Uint8Array(3 * 4 * 4);
+
+const getPalette = (c, layer = 0) =>
+  palettes.subarray((layer * 4 + c) * 4, (layer * 4 + c + 1) * 4);
 
 const gpuLCDC = document.querySelector(".gpu-lcdc");
 const gpuSTAT = document.querySelector(".gpu-stat");
@@ -105,7 +98,7 @@ const renderSprites = (ctx, oamram, vram, longSprite, obp0, obp1) => {
     const config = oamram[i * 4 + 3];
     const yFlip = (config & 0x40) !== 0;
     const xFlip = (config & 0x20) !== 0;
-    const isPallette1 = config & (0x10 != 0);
+    const isPallette1 = (config & 0x10) !== 0;
     const height = longSprite ? 16 : 8;
 
     for (let x = 0; x < 8; x++) {
@@ -123,7 +116,7 @@ const renderSprites = (ctx, oamram, vram, longSprite, obp0, obp1) => {
           ? (obp1 >> (paletteID * 2)) & 0x03
           : (obp0 >> (paletteID * 2)) & 0x03;
         if (paletteID !== 0) {
-          const c = getPalette(v);
+          const c = getPalette(v, isPallette1 ? 2 : 1);
           const base =
             ((offsetY + adjustedY) * 160 + (adjustedX + offsetX)) * 4;
           sprites[base] = c[0];
@@ -194,6 +187,7 @@ export const renderDebugInfo = gb => {
   let oamram = new Uint8Array(0x800 * 4);
   gb.getVRAM(vram);
   gb.getOAMRAM(oamram);
+  gb.getPalettes(palettes);
 
   const lcdc = gb.readGPU(0);
   const stat = gb.readGPU(1);
@@ -0,0 +1,52 @@
+package bus
+
+import (
+	"fmt"
+
+	"github.com/bokuweb/gopher-boy/pkg/types"
+)
+
+// AccessCheck is how CPU accesses to VRAM and OAM locked by PPU are handled.
+// VRAM is locked in mode 3 and OAM is locked in mode 2 and 3.
+type AccessCheck byte
+
+const (
+	// AccessBlock drops writes and reads 0xFF as the hardware does
+	AccessBlock AccessCheck = iota
+	// AccessWarn blocks accesses and logs a warning for each of them
+	AccessWarn
+	// AccessAllow never blocks accesses
+	AccessAllow
+)
+
+// SetAccessCheck sets how accesses to locked VRAM and OAM are handled.
+func (b *Bus) SetAccessCheck(c AccessCheck) {
+	b.accessCheck = c
+}
+
+// blocked reports whether CPU access to addr is blocked by PPU mode.
+func (b *Bus) blocked(addr types.Word, write bool) bool {
+	if b.accessCheck == AccessAllow {
+		return false
+	}
+	var accessible bool
+	switch {
+	case addr >= 0x8000 && addr <= 0x9FFF:
+		accessible = b.gpu.VRAMAccessible(write)
+	case addr >= 0xFE00 && addr <= 0xFE9F:
+		accessible = b.gpu.OAMAccessible(write)
+	default:
+		return false
+	}
+	if accessible {
+		return false
+	}
+	if b.accessCheck == AccessWarn {
+		op := "read"
+		if write {
+			op = "write"
+		}
+		b.logger.Warn(fmt.Sprintf("blocked %s at 0x%04X in PPU mode %d", op, uint16(addr), b.gpu.Mode()))
+	}
+	return true
+}
@@ -1,6 +1,8 @@
 package bus
 
 import (
+	"errors"
+
 	"github.com/bokuweb/gopher-boy/pkg/interfaces/pad"
 	"github.com/bokuweb/gopher-boy/pkg/interrupt"
 
@@ -15,14 +17,29 @@ import (
 )
 
 const (
-	// DMGStatusReg is DMA status register
+	// DMGStatusReg is boot ROM disable register
+	// Writing non zero value unmaps the boot ROM.
 	DMGStatusReg types.Word = 0xFF50
+	// SBReg is serial transfer data register
+	SBReg types.Word = 0xFF01
+	// SCReg is serial transfer control register, bit 7 starts transfer and bit 0 selects internal clock.
+	// Bit 1 selects fast clock in CGB mode.
+	SCReg types.Word = 0xFF02
+)
+
+const (
+	// DMGBootROMSize is DMG, MGB, SGB and SGB2 boot ROM size
+	DMGBootROMSize = 0x100
+	// CGBBootROMSize is CGB boot ROM size
+	// CGB boot ROM is mapped at 0x0000-0x00FF and 0x0200-0x08FF.
+	CGBBootROMSize = 0x900
 )
 
 // Bus is gb bus
 type Bus struct {
 	logger    logger.Logger
 	bootmode  bool
+	bootROM   []byte
 	cartridge *cartridge.Cartridge
 	gpu       *gpu.GPU
 	vRAM      *ram.RAM
@@ -32,6 +49,33 @@ type Bus struct {
 	timer     *timer.Timer
 	irq       *interrupt.Interrupt
 	pad       pad.Pad
+	sb        byte
+	sc        byte
+	sound     sound
+
+	accessCheck AccessCheck
+
+	// cgb enables CGB mode, see SetCGBMode
+	cgb      bool
+	vRAM1    *ram.RAM
+	vramBank byte
+	wRAMHigh *ram.RAM
+	wramBank byte
+	key0     byte
+	// doubleSpeed is current CPU speed and speedArmed is KEY1 bit 0
+	doubleSpeed bool
+	speedArmed  bool
+
+	dma  dma
+	hdma hdma
+	// M-cycles of CPU step accessed and ran DMA
+	stepping bool
+	accesses uint
+	synced   uint
+
+	watchID     int
+	watchpoints []watch
+	watchHit    *WatchHit
 }
 
 /* --------------------------+
@@ -74,11 +118,12 @@ func NewBus(
 	pad pad.Pad) *Bus {
 	return &Bus{
 		logger:    logger,
-		bootmode:  true,
+		bootmode:  false,
 		cartridge: cartridge,
 		gpu:       gpu,
 		vRAM:      vram,
 		wRAM:      wram,
+		wramBank:  1,
 		hRAM:      hRAM,
 		oamRAM:    oamRAM,
 		timer:     timer,
@@ -88,43 +133,77 @@ func NewBus(
 }
 
 // ReadByte is byte data reader from bus
+// VRAM and OAM locked by PPU read 0xFF, and see dma.conflict for accesses during OAM DMA.
 func (b *Bus) ReadByte(addr types.Word) byte {
+	b.sync()
+	data, conflicted := b.dma.conflict(addr)
+	if !conflicted {
+		data = 0xFF
+		if !b.blocked(addr, false) {
+			data = b.readByte(addr)
+		}
+	}
+	if len(b.watchpoints) != 0 {
+		b.watch(WatchRead, addr, data, data)
+	}
+	return data
+}
 
+func (b *Bus) readByte(addr types.Word) byte {
 	switch {
 	case addr >= 0x0000 && addr <= 0x7FFF:
-		if b.bootmode && addr < 0x0100 {
-			return BIOS[addr]
-		}
-		if addr == 0x0100 {
-			b.bootmode = false
+		if b.bootmode && b.isBootROMAddr(addr) {
+			return b.bootROM[addr]
 		}
 		return b.cartridge.ReadByte(addr)
 	// Video RAM
 	case addr >= 0x8000 && addr <= 0x9FFF:
-		return b.vRAM.Read(addr - 0x8000)
+		return b.vram().Read(addr - 0x8000)
 	case addr >= 0xA000 && addr <= 0xBFFF:
 		return b.cartridge.ReadByte(addr)
 	// Working RAM
 	case addr >= 0xC000 && addr <= 0xDFFF:
-		return b.wRAM.Read(addr - 0xC000)
+		r, offset := b.wram(addr)
+		return r.Read(offset)
 	// Shadow
 	case addr >= 0xE000 && addr <= 0xFDFF:
-		return b.wRAM.Read(addr - 0xE000)
+		r, offset := b.wram(addr - 0x2000)
+		return r.Read(offset)
 	// OAM
 	case addr >= 0xFE00 && addr <= 0xFE9F:
 		return b.oamRAM.Read(addr - 0xFE00)
-	// Pad
+	// Pad, bit 6 and 7 are unused
 	case addr == 0xFF00:
-		return b.pad.Read()
+		return b.pad.Read() | 0xC0
+	// Serial
+	case addr == SBReg:
+		return b.sb
+	case addr == SCReg:
+		if b.cgb {
+			return b.sc | 0x7C
+		}
+		return b.sc | 0x7E
 	// Timer
 	case addr >= 0xFF04 && addr <= 0xFF07:
 		return b.timer.Read(addr - 0xFF00)
 	// IF
 	case addr == 0xFF0F:
 		return b.irq.Read(addr - 0xFF00)
+	// Sound
+	case addr >= NR10Reg && addr <= soundEnd:
+		return b.readSound(addr)
+	case addr == DMGStatusReg:
+		return 0xFF
+	case addr == KEY0Reg || addr == KEY1Reg || addr == VBKReg || addr == SVBKReg:
+		return b.readCGB(addr)
+	case addr >= HDMA1Reg && addr <= HDMA5Reg:
+		return b.readHDMA(addr)
 	// GPU
 	case addr >= 0xFF40 && addr <= 0xFF7F:
 		return b.gpu.Read(addr - 0xFF40)
+	// Unused I/O
+	case addr >= 0xFF00 && addr <= 0xFF7F:
+		return 0xFF
 	// Zero page RAM
 	case addr >= 0xFF80 && addr <= 0xFFFE:
 		return b.hRAM.Read(addr - 0xFF80)
@@ -143,22 +222,48 @@ func (b *Bus) ReadWord(addr types.Word) types.Word {
 	return utils.Bytes2Word(u, l)
 }
 
+// Peek reads addr for debuggers without side effects.
+// It neither advances DMA nor triggers watchpoints and access checks by PPU mode.
+func (b *Bus) Peek(addr types.Word) byte {
+	return b.readByte(addr)
+}
+
+// Poke writes addr for debuggers and boot emulation without side effects of CPU access.
+// Like Peek, it neither advances DMA nor triggers watchpoints and access checks by PPU mode.
+func (b *Bus) Poke(addr types.Word, data byte) {
+	b.writeByte(addr, data)
+}
+
 // WriteByte is byte data writer to bus
+// Writes to VRAM and OAM locked by PPU or conflicting with OAM DMA are dropped.
 func (b *Bus) WriteByte(addr types.Word, data byte) {
+	b.sync()
+	if _, conflicted := b.dma.conflict(addr); conflicted || b.blocked(addr, true) {
+		return
+	}
+	if len(b.watchpoints) != 0 {
+		b.watch(WatchWrite|WatchChange, addr, b.readByte(addr), data)
+	}
+	b.writeByte(addr, data)
+}
+
+func (b *Bus) writeByte(addr types.Word, data byte) {
 	switch {
 	case addr >= 0x0000 && addr <= 0x7FFF:
 		b.cartridge.WriteByte(addr, data)
 	// Video RAM
 	case addr >= 0x8000 && addr <= 0x9FFF:
-		b.vRAM.Write(addr-0x8000, data)
+		b.vram().Write(addr-0x8000, data)
 	case addr >= 0xA000 && addr <= 0xBFFF:
 		b.cartridge.WriteByte(addr, data)
 	// Working RAM
 	case addr >= 0xC000 && addr <= 0xDFFF:
-		b.wRAM.Write(addr-0xC000, data)
+		r, offset := b.wram(addr)
+		r.Write(offset, data)
 	// Shadow
 	case addr >= 0xE000 && addr <= 0xFDFF:
-		b.wRAM.Write(addr-0xE000, data)
+		r, offset := b.wram(addr - 0x2000)
+		r.Write(offset, data)
 	// OAM
 	case addr >= 0xFE00 && addr <= 0xFE9F:
 		b.oamRAM.Write(addr-0xFE00, data)
@@ -166,14 +271,31 @@ func (b *Bus) WriteByte(addr types.Word, data byte) {
 	case addr == 0xFF00:
 		b.pad.Write(data)
 	// Serial
-	case addr == 0xFF01:
+	case addr == SBReg:
+		b.sb = data
 		serial.Send(data)
+	case addr == SCReg:
+		b.sc = data & 0x83
 	// Timer
 	case addr >= 0xFF04 && addr <= 0xFF07:
 		b.timer.Write(addr-0xFF00, data)
 	// IF
 	case addr == 0xFF0F:
 		b.irq.Write(addr-0xFF00, data)
+	// Sound
+	case addr >= NR10Reg && addr <= soundEnd:
+		b.writeSound(addr, data)
+	case addr == DMAReg:
+		b.dma.request(data)
+		b.gpu.Write(addr-0xFF40, data)
+	case addr == DMGStatusReg:
+		if data != 0 && b.bootmode {
+			b.unmapBootROM()
+		}
+	case addr == KEY0Reg || addr == KEY1Reg || addr == VBKReg || addr == SVBKReg:
+		b.writeCGB(addr, data)
+	case addr >= HDMA1Reg && addr <= HDMA5Reg:
+		b.writeHDMA(addr, data)
 	// GPU
 	case addr >= 0xFF40 && addr <= 0xFF7F:
 		b.gpu.Write(addr-0xFF40, data)
@@ -195,5 +317,58 @@ func (b *Bus) WriteWord(addr types.Word, data types.Word) {
 	b.WriteByte(addr+1, upper)
 }
 
-// BIOS is
-var BIOS = []byte{ /* Removed*/ }
+// ReadVRAM reads VRAM for PPU, addr is 0x8000-0x9FFF.
+func (b *Bus) ReadVRAM(addr types.Word) byte {
+	return b.vRAM.Read(addr - 0x8000)
+}
+
+// ReadOAM reads OAM for PPU, addr is 0xFE00-0xFE9F.
+func (b *Bus) ReadOAM(addr types.Word) byte {
+	return b.oamRAM.Read(addr - 0xFE00)
+}
+
+// ROMBank returns ROM bank number mapped at addr.
+func (b *Bus) ROMBank(addr types.Word) int {
+	if addr >= 0x4000 && addr <= 0x7FFF {
+		return b.cartridge.ROMBank()
+	}
+	return 0
+}
+
+// SetBootROM maps boot ROM from 0x0000 until 0xFF50 is written.
+func (b *Bus) SetBootROM(buf []byte) error {
+	if len(buf) != DMGBootROMSize && len(buf) != CGBBootROMSize {
+		return errors.New("boot ROM size should be 256 or 2304 bytes")
+	}
+	b.bootROM = buf
+	b.bootmode = true
+	// DMA is stopped and sound is off at power-on.
+	b.dma = dma{}
+	b.hdma = hdma{}
+	b.sound = sound{}
+	b.sb, b.sc = 0x00, 0x00
+	// Nothing is left from the previous run in VRAM, OAM and HRAM, and P1 selects no keys.
+	b.vRAM.Clear()
+	if b.vRAM1 != nil {
+		b.vRAM1.Clear()
+	}
+	b.oamRAM.Clear()
+	b.hRAM.Clear()
+	b.pad.Write(0x30)
+	// CGB boot ROM runs in CGB mode until it selects the mode with KEY0.
+	b.key0 = 0x00
+	b.SetCGBMode(len(buf) == CGBBootROMSize)
+	return nil
+}
+
+// BootROMMapped reports whether boot ROM is still mapped.
+func (b *Bus) BootROMMapped() bool {
+	return b.bootmode
+}
+
+func (b *Bus) isBootROMAddr(addr types.Word) bool {
+	if addr < 0x0100 {
+		return true
+	}
+	return len(b.bootROM) == CGBBootROMSize && addr >= 0x0200 && addr < CGBBootROMSize
+}
@@ -26,7 +26,9 @@ func setup() (*Bus, *ram.RAM, *ram.RAM) {
 	l := logger.NewLogger(logger.LogLevel("Debug"))
 	t := timer.NewTimer()
 	irq := interrupt.NewInterrupt()
-	return NewBus(l, cart, gpu, vRAM, wRAM, hRAM, oamRAM, t, irq, pad), wRAM, hRAM
+	b := NewBus(l, cart, gpu, vRAM, wRAM, hRAM, oamRAM, t, irq, pad)
+	gpu.Init(b, irq)
+	return b, wRAM, hRAM
 }
 
 func TestWRAMReadWrite(t *testing.T) {
@@ -61,3 +63,362 @@ func TestZRAMReadWrite(t *testing.T) {
 	assert.Equal(byte(0xA5), hRAM.Read(0x0000))
 	assert.Equal(types.Word(0xDEAD), b.ReadWord(0xFF90))
 }
+
+func TestWatchpoint(t *testing.T) {
+	assert := assert.New(t)
+	b, _, _ := setup()
+	b.WriteByte(0xC000, 0xA5)
+	assert.Nil(b.WatchHit())
+
+	id := b.AddWatchpoint(Watchpoint{Start: 0xC000, End: 0xC0FF, Kind: WatchChange})
+	b.WriteByte(0xC000, 0xA5)
+	assert.Nil(b.WatchHit())
+	b.WriteByte(0xC010, 0x5A)
+	assert.Equal(&WatchHit{ID: id, Kind: WatchChange, Addr: 0xC010, Old: 0x00, Value: 0x5A}, b.WatchHit())
+	assert.Nil(b.WatchHit())
+
+	b.RemoveWatchpoint(id)
+	b.WriteByte(0xC010, 0x00)
+	assert.Nil(b.WatchHit())
+
+	id = b.AddWatchpoint(Watchpoint{Start: 0xFF80, End: 0xFF80, Kind: WatchRead})
+	b.ReadByte(0xFF81)
+	assert.Nil(b.WatchHit())
+	b.ReadByte(0xFF80)
+	assert.Equal(id, b.WatchHit().ID)
+}
+
+func TestBootROM(t *testing.T) {
+	assert := assert.New(t)
+	b, _, _ := setup()
+	assert.Error(b.SetBootROM(make([]byte, 0x10)))
+	boot := make([]byte, DMGBootROMSize)
+	boot[0x00] = 0x31
+	boot[0xFF] = 0x50
+	assert.NoError(b.SetBootROM(boot))
+	assert.Equal(byte(0x31), b.ReadByte(0x0000))
+	assert.Equal(byte(0x50), b.ReadByte(0x00FF))
+	assert.Equal(byte(0x00), b.ReadByte(0x0100))
+	b.WriteByte(DMGStatusReg, 0x01)
+	assert.False(b.BootROMMapped())
+	assert.Equal(byte(0x00), b.ReadByte(0x0000))
+
+	// Loading boot ROM again clears VRAM, OAM and HRAM, and resets P1.
+	b.WriteByte(0xFF40, 0x00)
+	b.WriteByte(0x8010, 0x12)
+	b.WriteByte(0xFE00, 0x34)
+	b.WriteByte(0xFF80, 0x56)
+	b.WriteByte(0xFF00, 0x10)
+	assert.Equal(byte(0x34), b.Peek(0xFE00))
+	assert.Equal(byte(0x10), b.ReadByte(0xFF00)&0x30)
+	assert.NoError(b.SetBootROM(boot))
+	assert.Equal(byte(0x00), b.Peek(0x8010))
+	assert.Equal(byte(0x00), b.Peek(0xFE00))
+	assert.Equal(byte(0x00), b.Peek(0xFF80))
+	assert.Equal(byte(0x30), b.ReadByte(0xFF00)&0x30)
+}
+
+func TestVRAMBank(t *testing.T) {
+	assert := assert.New(t)
+	b, _, _ := setup()
+	// VBK is ignored in DMG mode.
+	b.WriteByte(VBKReg, 0x01)
+	assert.Equal(byte(0xFF), b.ReadByte(VBKReg))
+	b.WriteByte(0x8000, 0x11)
+
+	b.SetCGBMode(true)
+	b.WriteByte(VBKReg, 0x01)
+	assert.Equal(byte(0xFF), b.ReadByte(VBKReg))
+	assert.Equal(byte(0x00), b.ReadByte(0x8000))
+	b.WriteByte(0x8000, 0x22)
+	b.WriteByte(VBKReg, 0x00)
+	assert.Equal(byte(0xFE), b.ReadByte(VBKReg))
+	assert.Equal(byte(0x11), b.ReadByte(0x8000))
+	assert.Equal(byte(0x11), b.ReadVRAMBank(0x8000, 0))
+	assert.Equal(byte(0x22), b.ReadVRAMBank(0x8000, 1))
+}
+
+func TestWRAMBank(t *testing.T) {
+	assert := assert.New(t)
+	b, wRAM, _ := setup()
+	b.WriteByte(0xD000, 0x11)
+	b.SetCGBMode(true)
+	b.WriteByte(SVBKReg, 0x02)
+	assert.Equal(byte(0xFA), b.ReadByte(SVBKReg))
+	assert.Equal(byte(0x00), b.ReadByte(0xD000))
+	b.WriteByte(0xD000, 0x22)
+	b.WriteByte(0xC000, 0x33)
+	assert.Equal(byte(0x22), b.ReadByte(0xF000))
+	assert.Equal(byte(0x33), wRAM.Read(0x0000))
+	// Bank 0 selects bank 1.
+	b.WriteByte(SVBKReg, 0x00)
+	assert.Equal(byte(0xF9), b.ReadByte(SVBKReg))
+	assert.Equal(byte(0x11), b.ReadByte(0xD000))
+}
+
+func TestSpeedSwitch(t *testing.T) {
+	assert := assert.New(t)
+	b, _, _ := setup()
+	b.WriteByte(KEY1Reg, 0x01)
+	assert.Equal(byte(0xFF), b.ReadByte(KEY1Reg))
+	assert.False(b.SwitchSpeed())
+
+	b.SetCGBMode(true)
+	assert.False(b.SwitchSpeed())
+	b.WriteByte(KEY1Reg, 0x01)
+	assert.Equal(byte(0x7F), b.ReadByte(KEY1Reg))
+	assert.True(b.SwitchSpeed())
+	assert.True(b.DoubleSpeed())
+	assert.Equal(byte(0xFE), b.ReadByte(KEY1Reg))
+}
+
+// startHDMA copies 0xC000-0xC03F to 0x8800 with HDMA5 value.
+func startHDMA(b *Bus, hdma5 byte) {
+	for i := 0; i < 0x40; i++ {
+		b.WriteByte(0xC000+types.Word(i), byte(i+1))
+	}
+	b.WriteByte(HDMA1Reg, 0xC0)
+	b.WriteByte(HDMA2Reg, 0x0F)
+	b.WriteByte(HDMA3Reg, 0xE8)
+	b.WriteByte(HDMA4Reg, 0x00)
+	b.WriteByte(HDMA5Reg, hdma5)
+}
+
+func TestGeneralHDMA(t *testing.T) {
+	assert := assert.New(t)
+	b, _, _ := setup()
+	b.SetCGBMode(true)
+	startHDMA(b, 0x03)
+	assert.Equal(uint(32), b.StepHDMA())
+	assert.Equal(uint(0), b.StepHDMA())
+	assert.Equal(byte(0x01), b.ReadByte(0x8800))
+	assert.Equal(byte(0x40), b.ReadByte(0x883F))
+	assert.Equal(byte(0xFF), b.ReadByte(HDMA5Reg))
+
+	b.WriteByte(KEY1Reg, 0x01)
+	b.SwitchSpeed()
+	startHDMA(b, 0x00)
+	assert.Equal(uint(16), b.StepHDMA())
+}
+
+func TestHBlankHDMA(t *testing.T) {
+	assert := assert.New(t)
+	b, _, _ := setup()
+	b.gpu.Init(b, interrupt.NewInterrupt())
+	b.gpu.PowerOn()
+	b.SetCGBMode(true)
+	startHDMA(b, 0x83)
+	assert.Equal(byte(0x03), b.ReadByte(HDMA5Reg))
+	assert.Equal(uint(0), b.StepHDMA())
+	b.WriteByte(0xFF40, 0x80)
+	// A block is copied at the end of mode 3 of each line.
+	cycles := uint(0)
+	for i := 0; i < 456*2; i++ {
+		b.gpu.Step(1)
+		cycles += b.StepHDMA()
+	}
+	assert.Equal(uint(16), cycles)
+	assert.Equal(byte(0x01), b.ReadByte(HDMA5Reg))
+	assert.Equal(byte(0x20), b.ReadByte(0x881F))
+	assert.Equal(byte(0x00), b.ReadByte(0x8820))
+
+	// Writing bit 7 = 0 cancels the transfer.
+	b.WriteByte(HDMA5Reg, 0x00)
+	assert.Equal(byte(0x81), b.ReadByte(HDMA5Reg))
+	assert.False(b.HDMAActive())
+	assert.Equal(uint(0), b.StepHDMA())
+}
+
+func TestHBlankHDMAStartedInHBlank(t *testing.T) {
+	assert := assert.New(t)
+	b, _, _ := setup()
+	b.gpu.Init(b, interrupt.NewInterrupt())
+	b.gpu.PowerOn()
+	b.SetCGBMode(true)
+	b.WriteByte(0xFF40, 0x80)
+	b.gpu.Step(456 + 80 + 172 + 8)
+	assert.Equal(gpu.HBlankMode, b.gpu.Mode())
+	assert.Equal(uint(0), b.StepHDMA())
+	// The first block is copied at once without waiting for the next HBlank.
+	startHDMA(b, 0x81)
+	assert.Equal(uint(8), b.StepHDMA())
+	assert.Equal(byte(0x00), b.ReadByte(HDMA5Reg))
+	assert.Equal(byte(0x10), b.ReadByte(0x880F))
+	assert.Equal(uint(0), b.StepHDMA())
+	assert.Equal(byte(0x00), b.ReadByte(0x8810))
+}
+
+func TestKEY0(t *testing.T) {
+	assert := assert.New(t)
+	b, _, _ := setup()
+	assert.NoError(b.SetBootROM(make([]byte, CGBBootROMSize)))
+	assert.True(b.CGBMode())
+	// Boot ROM selects DMG compatible mode.
+	b.WriteByte(KEY0Reg, 0x04)
+	assert.True(b.CGBMode())
+	b.WriteByte(DMGStatusReg, 0x01)
+	assert.False(b.CGBMode())
+
+	assert.NoError(b.SetBootROM(make([]byte, CGBBootROMSize)))
+	b.WriteByte(KEY0Reg, 0x80)
+	b.WriteByte(DMGStatusReg, 0x01)
+	assert.True(b.CGBMode())
+}
+
+func TestLockedVRAMAndOAM(t *testing.T) {
+	assert := assert.New(t)
+	b, _, _ := setup()
+	b.gpu.Init(b, interrupt.NewInterrupt())
+	b.gpu.PowerOn()
+	b.WriteByte(0x8000, 0x11)
+	b.WriteByte(0xFE00, 0x22)
+	// LCD on starts from mode 0 and OAM reads are locked from the start of the next line.
+	b.WriteByte(0xFF40, 0x80)
+	assert.Equal(byte(0x11), b.ReadByte(0x8000))
+	assert.Equal(byte(0x22), b.ReadByte(0xFE00))
+	b.gpu.Step(456 - 4)
+	assert.Equal(gpu.HBlankMode, b.gpu.Mode())
+	assert.Equal(byte(0x11), b.ReadByte(0x8000))
+	assert.Equal(byte(0xFF), b.ReadByte(0xFE00))
+	b.gpu.Step(4)
+	assert.Equal(gpu.SearchingOAMMode, b.gpu.Mode())
+	b.WriteByte(0xFE00, 0x33)
+
+	b.gpu.Step(80)
+	assert.Equal(gpu.TransferingData, b.gpu.Mode())
+	assert.Equal(byte(0xFF), b.ReadByte(0x8000))
+	assert.Equal(byte(0xFF), b.ReadByte(0xFE00))
+	b.WriteByte(0x8000, 0x33)
+
+	b.SetAccessCheck(AccessWarn)
+	assert.Equal(byte(0xFF), b.ReadByte(0x8000))
+	b.SetAccessCheck(AccessAllow)
+	assert.Equal(byte(0x11), b.ReadByte(0x8000))
+	assert.Equal(byte(0x22), b.ReadByte(0xFE00))
+	b.SetAccessCheck(AccessBlock)
+
+	b.WriteByte(0xFF40, 0x00)
+	assert.Equal(byte(0x11), b.ReadByte(0x8000))
+	assert.Equal(byte(0x22), b.ReadByte(0xFE00))
+}
+
+func TestOAMDMA(t *testing.T) {
+	assert := assert.New(t)
+	b, _, _ := setup()
+	b.gpu.PowerOn()
+	for i := 0; i < oamSize; i++ {
+		b.WriteByte(0xC000+types.Word(i), byte(i+1))
+	}
+	b.WriteByte(0xFF80, 0x12)
+
+	// LDH (DMA),A writes at the third M-cycle.
+	b.BeginStep()
+	b.ReadByte(0x0000)
+	b.ReadByte(0x0001)
+	b.WriteByte(DMAReg, 0xC0)
+	b.EndStep(3)
+	assert.Equal(byte(0xC0), b.ReadByte(DMAReg))
+	// The first byte is transferred after the start delay.
+	assert.False(b.DMAActive())
+	b.BeginStep()
+	assert.Equal(byte(0x00), b.ReadByte(0xFE00))
+	assert.Equal(byte(0xFF), b.ReadByte(0xFE00))
+	b.EndStep(2)
+	assert.True(b.DMAActive())
+
+	b.BeginStep()
+	// Bus DMA reads from returns the transferred byte and HRAM is accessible.
+	assert.Equal(byte(0x02), b.ReadByte(0xC080))
+	assert.Equal(byte(0x12), b.ReadByte(0xFF80))
+	b.EndStep(2)
+	b.BeginStep()
+	b.EndStep(oamSize - 5)
+	assert.True(b.DMAActive())
+	b.BeginStep()
+	b.EndStep(1)
+	assert.False(b.DMAActive())
+	for i := 0; i < oamSize; i++ {
+		assert.Equal(byte(i+1), b.ReadOAM(0xFE00+types.Word(i)))
+	}
+}
+
+func TestPeekDoesNotAdvanceDMA(t *testing.T) {
+	assert := assert.New(t)
+	b, _, _ := setup()
+	b.gpu.PowerOn()
+	b.WriteByte(0xC000, 0x01)
+	b.AddWatchpoint(Watchpoint{Start: 0xFE00, End: 0xFE00, Kind: WatchRead})
+	b.BeginStep()
+	b.ReadByte(0x0000)
+	b.ReadByte(0x0001)
+	b.WriteByte(DMAReg, 0xC0)
+	b.EndStep(3)
+
+	b.BeginStep()
+	for i := 0; i < 4; i++ {
+		b.Peek(0xFE00)
+		b.Peek(0xC000)
+	}
+	assert.Nil(b.WatchHit())
+	// Peeks are not M-cycles, so the first access still sees the start delay.
+	assert.Equal(byte(0x00), b.ReadByte(0xFE00))
+	assert.Equal(byte(0xFF), b.ReadByte(0xFE00))
+	b.EndStep(2)
+	assert.True(b.DMAActive())
+}
+
+func TestPoke(t *testing.T) {
+	assert := assert.New(t)
+	b, _, _ := setup()
+	b.gpu.Init(b, interrupt.NewInterrupt())
+	b.gpu.PowerOn()
+	b.WriteByte(0xFF40, 0x80)
+	b.gpu.Step(456 + 80)
+	assert.Equal(gpu.TransferingData, b.gpu.Mode())
+	b.AddWatchpoint(Watchpoint{Start: 0x8000, End: 0x8000, Kind: WatchWrite})
+	// Pokes land in VRAM locked by mode 3 and are not seen by watchpoints.
+	b.Poke(0x8000, 0x5A)
+	assert.Nil(b.WatchHit())
+	assert.Equal(byte(0xFF), b.ReadByte(0x8000))
+	assert.Equal(byte(0x5A), b.Peek(0x8000))
+}
+
+func TestUnusedIOBits(t *testing.T) {
+	assert := assert.New(t)
+	b, _, _ := setup()
+	assert.Equal(byte(0xFF), b.ReadByte(0xFF03))
+	assert.Equal(byte(0xFF), b.ReadByte(0xFF4E))
+	assert.Equal(byte(0xFF), b.ReadByte(0xFF7F))
+	assert.Equal(byte(0xFF), b.ReadByte(0xFF00))
+	b.WriteByte(SCReg, 0x81)
+	assert.Equal(byte(0xFF), b.ReadByte(SCReg))
+	b.WriteByte(SCReg, 0x00)
+	assert.Equal(byte(0x7E), b.ReadByte(SCReg))
+}
+
+func TestSoundRegisters(t *testing.T) {
+	assert := assert.New(t)
+	b, _, _ := setup()
+	// Registers are read-only while sound is off.
+	b.WriteByte(0xFF12, 0xF3)
+	assert.Equal(byte(0x00), b.ReadByte(0xFF12))
+	assert.Equal(byte(0x70), b.ReadByte(NR52Reg))
+
+	b.WriteByte(NR52Reg, 0xFF)
+	b.SetSoundChannels(0x01)
+	assert.Equal(byte(0xF1), b.ReadByte(NR52Reg))
+	b.WriteByte(0xFF11, 0x80)
+	b.WriteByte(0xFF12, 0xF3)
+	b.WriteByte(0xFF13, 0x12)
+	assert.Equal(byte(0xBF), b.ReadByte(0xFF11))
+	assert.Equal(byte(0xF3), b.ReadByte(0xFF12))
+	assert.Equal(byte(0xFF), b.ReadByte(0xFF13))
+	b.WriteByte(WaveRAMStart, 0x5A)
+	assert.Equal(byte(0x5A), b.ReadByte(WaveRAMStart))
+
+	// Turning sound off clears registers, but not wave RAM.
+	b.WriteByte(NR52Reg, 0x00)
+	assert.Equal(byte(0x70), b.ReadByte(NR52Reg))
+	assert.Equal(byte(0x00), b.ReadByte(0xFF12))
+	assert.Equal(byte(0x5A), b.ReadByte(WaveRAMStart))
+}
@@ -0,0 +1,148 @@
+package bus
+
+import (
+	"github.com/bokuweb/gopher-boy/pkg/ram"
+	"github.com/bokuweb/gopher-boy/pkg/timer"
+	"github.com/bokuweb/gopher-boy/pkg/types"
+)
+
+const (
+	// KEY0Reg is CGB mode register which only boot ROM can write.
+	// Bit 2 selects DMG compatible mode when boot ROM is unmapped.
+	KEY0Reg types.Word = 0xFF4C
+	// KEY1Reg is speed switch register, bit 7 is current speed and
+	// writing bit 0 arms the switch which STOP performs.
+	KEY1Reg types.Word = 0xFF4D
+	// VBKReg is VRAM bank register, bit 0 selects bank of 0x8000-0x9FFF in CGB mode.
+	VBKReg types.Word = 0xFF4F
+	// SVBKReg is WRAM bank register, bit 0-2 select bank of 0xD000-0xDFFF in CGB mode.
+	// Bank 0 selects bank 1.
+	SVBKReg types.Word = 0xFF70
+)
+
+const (
+	vramBankSize = 0x2000
+	wramBankSize = 0x1000
+	// CGB has 8 WRAM banks, bank 0 and 1 are in wRAM and bank 2-7 are in wRAMHigh.
+	wramBanks = 8
+)
+
+// SetCGBMode switches CGB mode of bus and GPU.
+// VRAM bank 1 is only accessible in CGB mode.
+func (b *Bus) SetCGBMode(on bool) {
+	b.cgb = on
+	b.vramBank = 0
+	b.wramBank = 1
+	b.doubleSpeed = false
+	b.speedArmed = false
+	b.hdma = hdma{}
+	if on && b.vRAM1 == nil {
+		b.vRAM1 = ram.NewRAM(vramBankSize)
+		b.wRAMHigh = ram.NewRAM(wramBankSize * (wramBanks - 2))
+	}
+	b.gpu.SetCGBMode(on)
+}
+
+// CGBMode reports whether CGB mode is enabled.
+func (b *Bus) CGBMode() bool {
+	return b.cgb
+}
+
+// vram returns VRAM bank selected by VBK.
+func (b *Bus) vram() *ram.RAM {
+	if b.vramBank == 1 {
+		return b.vRAM1
+	}
+	return b.vRAM
+}
+
+// wram returns WRAM and offset in it for 0xC000-0xDFFF.
+// 0xD000-0xDFFF is switched by SVBK in CGB mode.
+func (b *Bus) wram(addr types.Word) (*ram.RAM, types.Word) {
+	offset := addr - 0xC000
+	if offset < wramBankSize || b.wramBank <= 1 {
+		return b.wRAM, offset
+	}
+	return b.wRAMHigh, types.Word(b.wramBank-2)*wramBankSize + offset - wramBankSize
+}
+
+// DoubleSpeed reports whether CPU runs in CGB double speed mode.
+// CPU, timer and OAM DMA run twice as fast while PPU does not.
+func (b *Bus) DoubleSpeed() bool {
+	return b.doubleSpeed
+}
+
+// SwitchSpeed switches CPU speed if it is armed by KEY1, which STOP does.
+// It reports whether the speed is switched. DIV is reset by the switch.
+func (b *Bus) SwitchSpeed() bool {
+	if !b.cgb || !b.speedArmed {
+		return false
+	}
+	b.speedArmed = false
+	b.doubleSpeed = !b.doubleSpeed
+	b.timer.Write(timer.DIV, 0)
+	return true
+}
+
+func (b *Bus) readCGB(addr types.Word) byte {
+	if !b.cgb {
+		return 0xFF
+	}
+	switch addr {
+	case KEY1Reg:
+		var key1 byte = 0x7E
+		if b.doubleSpeed {
+			key1 |= 0x80
+		}
+		if b.speedArmed {
+			key1 |= 0x01
+		}
+		return key1
+	case VBKReg:
+		return 0xFE | b.vramBank
+	case SVBKReg:
+		return 0xF8 | b.wramBank
+	}
+	return 0xFF
+}
+
+func (b *Bus) writeCGB(addr types.Word, data byte) {
+	switch addr {
+	case KEY0Reg:
+		if b.bootmode {
+			b.key0 = data
+		}
+	case KEY1Reg:
+		if b.cgb {
+			b.speedArmed = data&0x01 != 0
+		}
+	case VBKReg:
+		if b.cgb {
+			b.vramBank = data & 0x01
+		}
+	case SVBKReg:
+		if b.cgb {
+			b.wramBank = data & 0x07
+			if b.wramBank == 0 {
+				b.wramBank = 1
+			}
+		}
+	}
+}
+
+// unmapBootROM unmaps boot ROM. CGB boot ROM selects DMG compatible mode
+// with KEY0 for DMG cartridges.
+func (b *Bus) unmapBootROM() {
+	b.bootmode = false
+	if len(b.bootROM) == CGBBootROMSize {
+		b.SetCGBMode(b.key0&0x04 == 0)
+	}
+}
+
+// ReadVRAMBank reads VRAM bank 0 or 1 for PPU, addr is 0x8000-0x9FFF.
+func (b *Bus) ReadVRAMBank(addr types.Word, bank byte) byte {
+	if bank == 1 && b.vRAM1 != nil {
+		return b.vRAM1.Read(addr - 0x8000)
+	}
+	return b.vRAM.Read(addr - 0x8000)
+}
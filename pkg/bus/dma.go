@@ -0,0 +1,88 @@
+package bus
+
+import "github.com/bokuweb/gopher-boy/pkg/types"
+
+const (
+	// DMAReg is OAM DMA register, writing starts transfer from (data << 8) to OAM
+	DMAReg  types.Word = 0xFF46
+	oamSize            = 0xA0
+	// dmaStartDelay is M-cycles from the write to the transfer of the first byte.
+	dmaStartDelay = 2
+)
+
+// dma is OAM DMA engine which copies a byte per M-cycle in parallel with CPU.
+// Writing DMA during a transfer restarts it, the old transfer keeps running
+// until the new one starts.
+type dma struct {
+	requested bool
+	delay     int
+	next      types.Word
+
+	running bool
+	src     types.Word
+	index   types.Word
+	// busy is true when a byte is transferred in the current M-cycle
+	busy bool
+	// value is the byte on the bus which is transferred in the current M-cycle
+	value byte
+}
+
+func (d *dma) request(data byte) {
+	d.requested = true
+	d.delay = 0
+	d.next = types.Word(data) << 8
+}
+
+// conflict returns what CPU sees on addr while DMA occupies the bus.
+// OAM reads 0xFF and the bus DMA reads from returns the transferred byte.
+// HRAM and I/O registers are always accessible.
+func (d *dma) conflict(addr types.Word) (byte, bool) {
+	if !d.busy || addr >= 0xFF00 {
+		return 0, false
+	}
+	if addr >= 0xFE00 {
+		return 0xFF, true
+	}
+	if isVRAMAddr(addr) == isVRAMAddr(d.src) {
+		return d.value, true
+	}
+	return 0, false
+}
+
+func isVRAMAddr(addr types.Word) bool {
+	return addr >= 0x8000 && addr <= 0x9FFF
+}
+
+// stepDMA runs OAM DMA for a M-cycle.
+func (b *Bus) stepDMA() {
+	d := &b.dma
+	if d.requested {
+		d.delay++
+		if d.delay == dmaStartDelay {
+			d.requested = false
+			d.running = true
+			d.src = d.next
+			d.index = 0
+		}
+	}
+	d.busy = d.running
+	if !d.running {
+		return
+	}
+	src := d.src + d.index
+	// Sources above 0xDFFF are mirror of WRAM.
+	if src >= 0xE000 {
+		src -= 0x2000
+	}
+	d.value = b.readByte(src)
+	b.oamRAM.Write(d.index, d.value)
+	d.index++
+	if d.index == oamSize {
+		d.running = false
+	}
+}
+
+// DMAActive reports whether OAM DMA is transferring.
+func (b *Bus) DMAActive() bool {
+	return b.dma.running
+}
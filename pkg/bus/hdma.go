@@ -0,0 +1,133 @@
+package bus
+
+import (
+	"github.com/bokuweb/gopher-boy/pkg/gpu"
+	"github.com/bokuweb/gopher-boy/pkg/types"
+)
+
+const (
+	// HDMA1Reg and HDMA2Reg are source address of VRAM DMA, lower 4 bits are ignored.
+	HDMA1Reg types.Word = 0xFF51
+	HDMA2Reg types.Word = 0xFF52
+	// HDMA3Reg and HDMA4Reg are destination address in VRAM, upper 3 and lower 4 bits are ignored.
+	HDMA3Reg types.Word = 0xFF53
+	HDMA4Reg types.Word = 0xFF54
+	// HDMA5Reg starts VRAM DMA of (bit 0-6 + 1) * 0x10 bytes.
+	// Bit 7 selects HBlank DMA, otherwise general purpose DMA copies all at once.
+	// Writing bit 7 = 0 during HBlank DMA cancels it.
+	HDMA5Reg types.Word = 0xFF55
+
+	hdmaBlockSize = 0x10
+	// hdmaBlockCycles is M-cycles to copy a block in normal speed,
+	// which doubles in double speed mode.
+	hdmaBlockCycles = 8
+)
+
+// hdma is CGB VRAM DMA engine. CPU is stalled while blocks are copied.
+type hdma struct {
+	src types.Word
+	dst types.Word
+	// blocks is remaining number of blocks minus 1 as HDMA5 reads
+	blocks byte
+	// general is true when general purpose DMA is requested
+	general bool
+	// hblank is true while HBlank DMA is active
+	hblank bool
+	// started is true until the first check after HBlank DMA is started
+	started bool
+	// mode is PPU mode at the last check
+	mode gpu.GPUMode
+}
+
+func (b *Bus) readHDMA(addr types.Word) byte {
+	if !b.cgb || addr != HDMA5Reg {
+		return 0xFF
+	}
+	// Bit 7 is 0 while HBlank DMA is active.
+	if b.hdma.hblank {
+		return b.hdma.blocks
+	}
+	return 0x80 | b.hdma.blocks
+}
+
+func (b *Bus) writeHDMA(addr types.Word, data byte) {
+	if !b.cgb {
+		return
+	}
+	h := &b.hdma
+	switch addr {
+	case HDMA1Reg:
+		h.src = types.Word(data)<<8 | h.src&0x00FF
+	case HDMA2Reg:
+		h.src = h.src&0xFF00 | types.Word(data&0xF0)
+	case HDMA3Reg:
+		h.dst = types.Word(data&0x1F)<<8 | h.dst&0x00FF
+	case HDMA4Reg:
+		h.dst = h.dst&0xFF00 | types.Word(data&0xF0)
+	case HDMA5Reg:
+		if h.hblank && data&0x80 == 0 {
+			h.hblank = false
+			return
+		}
+		h.blocks = data & 0x7F
+		if data&0x80 != 0 {
+			h.hblank = true
+			h.started = true
+			return
+		}
+		h.general = true
+	}
+}
+
+// copyHDMABlock copies a block to VRAM bank selected by VBK.
+func (b *Bus) copyHDMABlock() {
+	h := &b.hdma
+	for i := 0; i < hdmaBlockSize; i++ {
+		b.vram().Write(h.dst&0x1FFF, b.readByte(h.src))
+		h.src++
+		h.dst++
+	}
+	h.blocks--
+}
+
+// hdmaCycles returns M-cycles to copy n blocks.
+func (b *Bus) hdmaCycles(n uint) uint {
+	if b.doubleSpeed {
+		return n * hdmaBlockCycles * 2
+	}
+	return n * hdmaBlockCycles
+}
+
+// StepHDMA runs VRAM DMA and returns M-cycles CPU is stalled by it.
+// General purpose DMA copies all blocks at once, and
+// HBlank DMA copies a block when PPU enters HBlank from mode 3,
+// or at once when it is started during HBlank.
+func (b *Bus) StepHDMA() uint {
+	h := &b.hdma
+	if h.general {
+		h.general = false
+		n := uint(h.blocks) + 1
+		for i := uint(0); i < n; i++ {
+			b.copyHDMABlock()
+		}
+		return b.hdmaCycles(n)
+	}
+	mode := b.gpu.Mode()
+	entered := (h.mode == gpu.TransferingData || h.started) && mode == gpu.HBlankMode
+	h.mode = mode
+	h.started = false
+	// PPU also enters mode 0 when LCD is turned off.
+	if !h.hblank || !entered || b.gpu.Read(gpu.LCDC)&0x80 == 0 {
+		return 0
+	}
+	b.copyHDMABlock()
+	if h.blocks == 0xFF {
+		h.hblank = false
+	}
+	return b.hdmaCycles(1)
+}
+
+// HDMAActive reports whether HBlank DMA is active.
+func (b *Bus) HDMAActive() bool {
+	return b.hdma.hblank
+}
@@ -0,0 +1,71 @@
+package bus
+
+import "github.com/bokuweb/gopher-boy/pkg/types"
+
+const (
+	// NR10Reg is the first sound register, sound registers and wave RAM are at 0xFF10-0xFF3F.
+	NR10Reg types.Word = 0xFF10
+	// NR52Reg is sound on/off register, bit 7 turns sound on and bit 0-3 are channel on flags.
+	NR52Reg types.Word = 0xFF26
+	// WaveRAMStart is the start of wave pattern RAM of channel 3.
+	WaveRAMStart types.Word = 0xFF30
+	soundEnd     types.Word = 0xFF3F
+)
+
+// soundReadMasks are bits of 0xFF10-0xFF2F which always read as 1.
+// Write-only and unused registers read 0xFF.
+var soundReadMasks = [0x20]byte{
+	0x80, 0x3F, 0x00, 0xFF, 0xBF, // NR10-NR14
+	0xFF, 0x3F, 0x00, 0xFF, 0xBF, // NR21-NR24
+	0x7F, 0xFF, 0x9F, 0xFF, 0xBF, // NR30-NR34
+	0xFF, 0xFF, 0x00, 0x00, 0xBF, // NR41-NR44
+	0x00, 0x00, 0x70, // NR50-NR52
+	0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF,
+}
+
+// sound keeps sound registers so that they read back as on the hardware.
+// APU is not emulated, so channels are never turned on or off by sound.
+type sound struct {
+	regs [soundEnd - NR10Reg + 1]byte
+	// channels are channel on flags of NR52
+	channels byte
+}
+
+func (b *Bus) readSound(addr types.Word) byte {
+	s := &b.sound
+	if addr >= WaveRAMStart {
+		return s.regs[addr-NR10Reg]
+	}
+	data := s.regs[addr-NR10Reg] | soundReadMasks[addr-NR10Reg]
+	if addr == NR52Reg {
+		data |= s.channels
+	}
+	return data
+}
+
+func (b *Bus) writeSound(addr types.Word, data byte) {
+	s := &b.sound
+	on := s.regs[NR52Reg-NR10Reg]&0x80 != 0
+	switch {
+	case addr >= WaveRAMStart:
+		s.regs[addr-NR10Reg] = data
+	case addr == NR52Reg:
+		// Turning sound off clears all sound registers.
+		if data&0x80 == 0 {
+			for i := range s.regs[:WaveRAMStart-NR10Reg] {
+				s.regs[i] = 0
+			}
+			s.channels = 0
+		}
+		s.regs[NR52Reg-NR10Reg] = data & 0x80
+	case on:
+		// Registers are read-only while sound is off.
+		s.regs[addr-NR10Reg] = data
+	}
+}
+
+// SetSoundChannels sets channel on flags of NR52 (bit 0-3).
+// Boot ROM leaves channel 1 on with the startup sound.
+func (b *Bus) SetSoundChannels(channels byte) {
+	b.sound.channels = channels & 0x0F
+}
@@ -0,0 +1,49 @@
+package bus
+
+import "github.com/bokuweb/gopher-boy/pkg/interrupt"
+
+// BeginStep marks the start of CPU step.
+// Until EndStep, each bus access is regarded as a M-cycle and DMA, PPU and
+// timer advance through the preceding M-cycles, so CPU sees their state at
+// the start of the M-cycle of the access.
+func (b *Bus) BeginStep() {
+	b.stepping = true
+}
+
+// EndStep runs DMA, PPU and timer for the rest of the step which took cycles M-cycles.
+// It is also used without BeginStep to run them while CPU does not access the bus.
+func (b *Bus) EndStep(cycles uint) {
+	for b.synced < cycles {
+		b.tick()
+		b.synced++
+	}
+	b.stepping = false
+	b.accesses = 0
+	b.synced = 0
+}
+
+// sync runs DMA, PPU and timer until the start of the M-cycle of the current access.
+func (b *Bus) sync() {
+	if !b.stepping {
+		return
+	}
+	for b.synced < b.accesses {
+		b.tick()
+		b.synced++
+	}
+	b.accesses++
+}
+
+// tick runs a M-cycle. PPU runs at the same rate in CGB double speed mode,
+// which is 2 dots per M-cycle.
+func (b *Bus) tick() {
+	b.stepDMA()
+	dots := uint(4)
+	if b.doubleSpeed {
+		dots = 2
+	}
+	b.gpu.Step(dots)
+	if overflowed := b.timer.Update(1); overflowed {
+		b.irq.SetIRQ(interrupt.TimerOverflowFlag)
+	}
+}
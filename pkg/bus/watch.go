@@ -0,0 +1,82 @@
+package bus
+
+import "github.com/bokuweb/gopher-boy/pkg/types"
+
+// WatchKind is kind of memory access to watch
+type WatchKind byte
+
+const (
+	// WatchRead triggers on read access
+	WatchRead WatchKind = 1 << iota
+	// WatchWrite triggers on write access
+	WatchWrite
+	// WatchChange triggers on write access which changes the value
+	WatchChange
+)
+
+// Watchpoint watches memory accesses in [Start, End].
+// Watchpoints see CPU accesses, but not PPU fetches and OAM DMA transfers.
+// Writes blocked by PPU mode are not seen either.
+type Watchpoint struct {
+	Start types.Word
+	End   types.Word
+	Kind  WatchKind
+}
+
+// WatchHit is triggered watchpoint information.
+type WatchHit struct {
+	ID    int
+	Kind  WatchKind
+	Addr  types.Word
+	Old   byte
+	Value byte
+}
+
+type watch struct {
+	id int
+	Watchpoint
+}
+
+// AddWatchpoint adds watchpoint and returns its id.
+func (b *Bus) AddWatchpoint(w Watchpoint) int {
+	b.watchID++
+	b.watchpoints = append(b.watchpoints, watch{b.watchID, w})
+	return b.watchID
+}
+
+// RemoveWatchpoint removes watchpoint by id.
+func (b *Bus) RemoveWatchpoint(id int) {
+	for i, w := range b.watchpoints {
+		if w.id == id {
+			b.watchpoints = append(b.watchpoints[:i], b.watchpoints[i+1:]...)
+			return
+		}
+	}
+}
+
+// WatchHit returns the first watchpoint triggered since last call, and clears it.
+func (b *Bus) WatchHit() *WatchHit {
+	h := b.watchHit
+	b.watchHit = nil
+	return h
+}
+
+func (b *Bus) watch(kind WatchKind, addr types.Word, old, value byte) {
+	if b.watchHit != nil {
+		return
+	}
+	for _, w := range b.watchpoints {
+		if addr < w.Start || addr > w.End {
+			continue
+		}
+		k := w.Kind & kind
+		if k == 0 {
+			continue
+		}
+		if k == WatchChange && old == value {
+			continue
+		}
+		b.watchHit = &WatchHit{ID: w.id, Kind: k, Addr: addr, Old: old, Value: value}
+		return
+	}
+}
@@ -110,6 +110,11 @@ func (c *Cartridge) ReadByte(addr types.Word) byte {
 	return c.mbc.Read(addr)
 }
 
+// ROMBank returns ROM bank number mapped at 0x4000-0x7FFF
+func (c *Cartridge) ROMBank() int {
+	return c.mbc.romBank()
+}
+
 func (c *Cartridge) WriteByte(addr types.Word, data byte) {
 	c.mbc.Write(addr, data)
 }
@@ -9,4 +9,5 @@ type MBC interface {
 	Read(addr types.Word) byte
 	switchROMBank(bank int)
 	switchRAMBank(bank int)
+	romBank() int
 }
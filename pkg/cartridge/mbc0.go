@@ -32,3 +32,7 @@ func (m *MBC0) switchROMBank(bank int) {
 func (m *MBC0) switchRAMBank(bank int) {
 	// nop
 }
+
+func (m *MBC0) romBank() int {
+	return 1
+}
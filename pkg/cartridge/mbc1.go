@@ -129,3 +129,7 @@ func (m *MBC1) switchROMBank(bank int) {
 func (m *MBC1) switchRAMBank(bank int) {
 	m.selectedRAMBank = bank
 }
+
+func (m *MBC1) romBank() int {
+	return m.selectedROMBank
+}
@@ -43,10 +43,26 @@ type CPU struct {
 	irq     interrupt.Interrupt
 	stopped bool
 	halted  bool
+	tracer  *Tracer
+	// operands is reused by every instruction to avoid allocation
+	operands [2]byte
+	// branched is extra M-cycles taken by conditional jump, call and return
+	branched Cycle
 }
 
 type Cycle = uint
 
+// irqDispatchCycles is M-cycles to push PC and jump to interrupt handler
+const irqDispatchCycles Cycle = 5
+
+// speedSwitchCycles is M-cycles CPU is paused by CGB speed switch.
+const speedSwitchCycles Cycle = 2050
+
+// speedSwitcher is implemented by bus with CGB KEY1 register.
+type speedSwitcher interface {
+	SwitchSpeed() bool
+}
+
 // NewCPU is CPU constructor
 func NewCPU(logger logger.Logger, bus bus.Accessor, irq interrupt.Interrupt) *CPU {
 	cpu := &CPU{
@@ -71,6 +87,15 @@ func NewCPU(logger logger.Logger, bus bus.Accessor, irq interrupt.Interrupt) *CP
 	return cpu
 }
 
+// PowerOn resets registers to power-on state to run boot ROM from 0x0000.
+func (cpu *CPU) PowerOn() {
+	cpu.PC = 0x0000
+	cpu.SP = 0x0000
+	cpu.Regs = Registers{}
+	cpu.halted = false
+	cpu.stopped = false
+}
+
 func (cpu *CPU) fetch() byte {
 	d := cpu.bus.ReadByte(cpu.PC)
 	cpu.PC++
@@ -81,14 +106,17 @@ func (cpu *CPU) fetch() byte {
 func (cpu *CPU) Step() Cycle {
 
 	if cpu.halted {
-		if cpu.irq.HasIRQ() {
-			cpu.halted = false
+		if !cpu.irq.HasIRQ() {
+			return 0x01
 		}
-		return 0x01
+		// Wake up and dispatch the interrupt in the same step.
+		cpu.halted = false
 	}
-	// cpc := cpu.PC
 	if hasIRQ := cpu.resolveIRQ(); hasIRQ {
-		return 0x01
+		return irqDispatchCycles
+	}
+	if cpu.tracer != nil {
+		cpu.tracer.trace(cpu)
 	}
 	opcode := cpu.fetch()
 	var inst *inst
@@ -100,17 +128,16 @@ func (cpu *CPU) Step() Cycle {
 	}
 
 	operands := cpu.fetchOperands(inst.OperandsSize)
-	// cpu.logger.Info(fmt.Sprintf("PC = %X Opcode = %X %+v %+v %+v", cpc, opcode, cpu.Regs, inst, operands))
+	cpu.branched = 0
 	inst.Execute(cpu, operands)
-	return inst.Cycles
+	return inst.Cycles + cpu.branched
 }
 
 func (cpu *CPU) fetchOperands(size uint) []byte {
-	operands := []byte{}
-	for i := 0; i < int(size); i++ {
-		operands = append(operands, cpu.fetch())
+	for i := uint(0); i < size; i++ {
+		cpu.operands[i] = cpu.fetch()
 	}
-	return operands
+	return cpu.operands[:size]
 }
 
 type inst struct {
@@ -590,7 +617,7 @@ var instructions = []*inst{
 	&inst{0xCA, "JP Z,nn", 2, 3, func(cpu *CPU, operands []byte) { cpu.jpcc_nn(Z, true, operands) }},
 	EMPTY,
 	&inst{0xCC, "CALL Z,nn", 2, 3, func(cpu *CPU, operands []byte) { cpu.callcc_nn(Z, true, operands) }},
-	&inst{0xCD, "CALL nn", 2, 3, func(cpu *CPU, operands []byte) { cpu.call_nn(operands) }},
+	&inst{0xCD, "CALL nn", 2, 6, func(cpu *CPU, operands []byte) { cpu.call_nn(operands) }},
 	&inst{0xCE, "ADC A,#", 1, 2, func(cpu *CPU, operands []byte) { cpu.adca_n(operands[0]) }},
 	&inst{0xCF, "RST n", 0, 4, func(cpu *CPU, operands []byte) { cpu.rst(0x08) }},
 	&inst{0xD0, "RET NC", 0, 2, func(cpu *CPU, operands []byte) { cpu.retcc(C, false) }},
@@ -790,7 +817,12 @@ func (cpu *CPU) rrca() {
 // and screen until any button is pressed. The GB
 // and GBP screen goes white with a single dark
 // horizontal line. The GBC screen goes black.
+// On CGB, STOP switches CPU speed instead when it is armed by KEY1.
 func (cpu *CPU) stop() {
+	if s, ok := cpu.bus.(speedSwitcher); ok && s.SwitchSpeed() {
+		cpu.branched = speedSwitchCycles
+		return
+	}
 	cpu.stopped = true
 }
 
@@ -883,6 +915,7 @@ func (cpu *CPU) rra() {
 func (cpu *CPU) jrcc_n(flag flags, isSet bool, operands []byte) {
 	n := int8(operands[0])
 	if cpu.isSet(flag) == isSet {
+		cpu.branched = 1
 		if n != 0x00 {
 			if n < 0 {
 				cpu.PC -= types.Word(-n)
@@ -1327,6 +1360,7 @@ func (cpu *CPU) cp_n(v byte) {
 func (cpu *CPU) retcc(flag flags, isSet bool) {
 	if cpu.isSet(flag) == isSet {
 		cpu.pop2PC()
+		cpu.branched = 3
 	}
 }
 
@@ -1358,6 +1392,7 @@ func (cpu *CPU) pop_af() {
 func (cpu *CPU) jpcc_nn(flag flags, isSet bool, operands []byte) {
 	if cpu.isSet(flag) == isSet {
 		cpu.PC = utils.Bytes2Word(operands[1], operands[0])
+		cpu.branched = 1
 	}
 }
 
@@ -1384,6 +1419,7 @@ func (cpu *CPU) callcc_nn(flag flags, isSet bool, operands []byte) {
 		cpu.push(byte(cpu.PC >> 8))
 		cpu.push(byte(cpu.PC & 0xFF))
 		cpu.PC = utils.Bytes2Word(operands[1], operands[0])
+		cpu.branched = 3
 	}
 }
 
@@ -1942,6 +1978,16 @@ func (cpu *CPU) resolveIRQ() bool {
 	return true
 }
 
+// SetTracer sets instruction tracer, nil disables tracing.
+func (cpu *CPU) SetTracer(t *Tracer) {
+	cpu.tracer = t
+}
+
+// Halted reports whether cpu is halted and waiting for an interrupt.
+func (cpu *CPU) Halted() bool {
+	return cpu.halted
+}
+
 // For Debugging
 func (cpu *CPU) GetRegisters() Registers {
 	return cpu.Regs
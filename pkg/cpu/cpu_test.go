@@ -1,6 +1,9 @@
 package cpu
 
 import (
+	"bufio"
+	"bytes"
+	"errors"
 	"testing"
 
 	"github.com/bokuweb/gopher-boy/pkg/interrupt"
@@ -79,3 +82,89 @@ func TestLDnn_n(t *testing.T) {
 	cpu.Step()
 	assert.Equal(cpu.Regs.B, byte(0xA5), "should B equals 0xa5")
 }
+
+func TestTracer(t *testing.T) {
+	assert := assert.New(t)
+	cpu, _ := setupCPU(0, []byte{0x06, 0xA5, 0x00, 0x00})
+	cpu.PC = 0x00
+	buf := &bytes.Buffer{}
+	tracer := NewTracer(buf)
+	tracer.SetLimit(2)
+	cpu.SetTracer(tracer)
+	cpu.Step()
+	cpu.Step()
+	cpu.Step()
+	expected := "A:11 F:80 B:00 C:00 D:FF E:56 H:00 L:0D SP:FFFE PC:0000 PCMEM:06,A5,00,00\n" +
+		"A:11 F:80 B:A5 C:00 D:FF E:56 H:00 L:0D SP:FFFE PC:0002 PCMEM:00,00,00,00\n"
+	assert.Equal(expected, buf.String())
+	assert.True(tracer.Done())
+}
+
+func TestTracerFlush(t *testing.T) {
+	assert := assert.New(t)
+	cpu, _ := setupCPU(0, []byte{0x00, 0x00, 0x00})
+	cpu.PC = 0x00
+	buf := &bytes.Buffer{}
+	tracer := NewTracer(bufio.NewWriter(buf))
+	tracer.SetLimit(2)
+	cpu.SetTracer(tracer)
+	cpu.Step()
+	assert.Equal(0, buf.Len())
+	assert.NoError(tracer.Flush())
+	assert.Contains(buf.String(), "PC:0000")
+	// Buffered lines are flushed when the limit is reached.
+	cpu.Step()
+	assert.Contains(buf.String(), "PC:0001")
+}
+
+// failOnce is a writer whose first Flush fails.
+type failOnce struct {
+	bytes.Buffer
+	failed bool
+}
+
+func (w *failOnce) Flush() error {
+	if w.failed {
+		return nil
+	}
+	w.failed = true
+	return errors.New("disk full")
+}
+
+func TestTracerFlushError(t *testing.T) {
+	assert := assert.New(t)
+	cpu, _ := setupCPU(0, []byte{0x00, 0x00})
+	cpu.PC = 0x00
+	tracer := NewTracer(&failOnce{})
+	tracer.SetLimit(1)
+	cpu.SetTracer(tracer)
+	cpu.Step()
+	// The error of flush at the limit is kept for Flush.
+	assert.EqualError(tracer.Flush(), "disk full")
+}
+
+func TestTracerPCRange(t *testing.T) {
+	assert := assert.New(t)
+	cpu, _ := setupCPU(0, []byte{0x00, 0x00, 0x00})
+	cpu.PC = 0x00
+	buf := &bytes.Buffer{}
+	tracer := NewTracer(buf)
+	tracer.SetPCRange(0x01, 0x01)
+	cpu.SetTracer(tracer)
+	cpu.Step()
+	cpu.Step()
+	cpu.Step()
+	assert.Equal(uint(1), tracer.Count())
+	assert.Contains(buf.String(), "PC:0001")
+}
+
+func TestStepWithoutAllocation(t *testing.T) {
+	assert := assert.New(t)
+	// LD BC,$DEAD / JP $0000
+	cpu, _ := setupCPU(0, []byte{0x01, 0xAD, 0xDE, 0xC3, 0x00, 0x00})
+	cpu.PC = 0x00
+	allocs := testing.AllocsPerRun(100, func() {
+		cpu.Step()
+	})
+	assert.Equal(float64(0), allocs)
+}
@@ -0,0 +1,181 @@
+package cpu
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/bokuweb/gopher-boy/pkg/interrupt"
+	"github.com/bokuweb/gopher-boy/pkg/logger"
+	"github.com/bokuweb/gopher-boy/pkg/mocks"
+	"github.com/bokuweb/gopher-boy/pkg/types"
+)
+
+// SingleStepTests SM83 JSON suite
+// https://github.com/SingleStepTests/sm83
+//
+// Place v1/*.json into test/sm83 or set SM83_TEST_DIR.
+// Set SM83_CHECK_CYCLES=1 to also compare bus accesses of each cycle.
+const defaultSM83TestDir = "../../test/sm83"
+
+// Maximum reported failures per file.
+const maxSM83Failures = 3
+
+type sm83State struct {
+	PC  types.Word `json:"pc"`
+	SP  types.Word `json:"sp"`
+	A   byte       `json:"a"`
+	B   byte       `json:"b"`
+	C   byte       `json:"c"`
+	D   byte       `json:"d"`
+	E   byte       `json:"e"`
+	F   byte       `json:"f"`
+	H   byte       `json:"h"`
+	L   byte       `json:"l"`
+	IME *byte      `json:"ime"`
+	IE  *byte      `json:"ie"`
+	RAM [][2]int   `json:"ram"`
+}
+
+type sm83Test struct {
+	Name    string            `json:"name"`
+	Initial sm83State         `json:"initial"`
+	Final   sm83State         `json:"final"`
+	Cycles  []json.RawMessage `json:"cycles"`
+}
+
+func TestSM83(t *testing.T) {
+	dir := os.Getenv("SM83_TEST_DIR")
+	if dir == "" {
+		dir = defaultSM83TestDir
+	}
+	files, _ := filepath.Glob(filepath.Join(dir, "*.json"))
+	if len(files) == 0 {
+		t.Skipf("SM83 test files are not found in %s", dir)
+	}
+	sort.Strings(files)
+	checkCycles := os.Getenv("SM83_CHECK_CYCLES") != ""
+	for _, file := range files {
+		name := strings.TrimSuffix(filepath.Base(file), ".json")
+		t.Run(name, func(t *testing.T) {
+			if skipSM83(name) {
+				t.Skip("HALT and STOP are not supported by single step tests")
+			}
+			buf, err := ioutil.ReadFile(file)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var tests []sm83Test
+			if err := json.Unmarshal(buf, &tests); err != nil {
+				t.Fatal(err)
+			}
+			failures := 0
+			for _, tt := range tests {
+				errs := runSM83(&tt, checkCycles)
+				if len(errs) == 0 {
+					continue
+				}
+				failures++
+				if failures <= maxSM83Failures {
+					t.Errorf("%s:\n  %s", tt.Name, strings.Join(errs, "\n  "))
+				}
+			}
+			if failures > maxSM83Failures {
+				t.Errorf("%d of %d tests failed", failures, len(tests))
+			}
+		})
+	}
+}
+
+func skipSM83(name string) bool {
+	return name == "10" || name == "76"
+}
+
+func runSM83(tt *sm83Test, checkCycles bool) []string {
+	b := &mocks.MockBus{}
+	irq := interrupt.NewInterrupt()
+	cpu := NewCPU(logger.NewLogger(logger.LogLevel("Silent")), b, irq)
+	in := tt.Initial
+	cpu.PC, cpu.SP = in.PC, in.SP
+	cpu.Regs = Registers{A: in.A, B: in.B, C: in.C, D: in.D, E: in.E, F: in.F, H: in.H, L: in.L}
+	if in.IME != nil && *in.IME != 0 {
+		irq.Enable()
+	}
+	if in.IE != nil {
+		irq.Write(interrupt.IE, *in.IE)
+	}
+	for _, m := range in.RAM {
+		b.MockMemory[m[0]] = byte(m[1])
+	}
+	b.Record = true
+	cpu.Step()
+	b.Record = false
+
+	var errs []string
+	expect := func(name string, got, want int) {
+		if got != want {
+			errs = append(errs, fmt.Sprintf("%s = 0x%02X, want 0x%02X", name, got, want))
+		}
+	}
+	f := tt.Final
+	expect("PC", int(cpu.PC), int(f.PC))
+	expect("SP", int(cpu.SP), int(f.SP))
+	expect("A", int(cpu.Regs.A), int(f.A))
+	expect("F", int(cpu.Regs.F), int(f.F))
+	expect("B", int(cpu.Regs.B), int(f.B))
+	expect("C", int(cpu.Regs.C), int(f.C))
+	expect("D", int(cpu.Regs.D), int(f.D))
+	expect("E", int(cpu.Regs.E), int(f.E))
+	expect("H", int(cpu.Regs.H), int(f.H))
+	expect("L", int(cpu.Regs.L), int(f.L))
+	// EI is delayed by an instruction on hardware.
+	if f.IME != nil && !strings.HasPrefix(tt.Name, "fb ") {
+		ime := 0
+		if irq.Enabled() {
+			ime = 1
+		}
+		expect("IME", ime, int(*f.IME))
+	}
+	for _, m := range f.RAM {
+		expect(fmt.Sprintf("(%04X)", m[0]), int(b.MockMemory[m[0]]), m[1])
+	}
+	if checkCycles {
+		errs = append(errs, compareSM83Cycles(tt.Cycles, b.Accesses)...)
+	}
+	return errs
+}
+
+// compareSM83Cycles compares bus accesses of non idle cycles.
+// Each cycle is [addr, data, "r-m"] or null when the bus is idle.
+func compareSM83Cycles(cycles []json.RawMessage, accesses []mocks.MockAccess) []string {
+	var want []mocks.MockAccess
+	for _, c := range cycles {
+		var v []interface{}
+		if err := json.Unmarshal(c, &v); err != nil || len(v) != 3 {
+			continue
+		}
+		addr, _ := v[0].(float64)
+		data, _ := v[1].(float64)
+		pins, _ := v[2].(string)
+		switch {
+		case strings.Contains(pins, "r"):
+			want = append(want, mocks.MockAccess{Addr: types.Word(addr), Data: byte(data)})
+		case strings.Contains(pins, "w"):
+			want = append(want, mocks.MockAccess{Addr: types.Word(addr), Data: byte(data), Write: true})
+		}
+	}
+	if len(want) != len(accesses) {
+		return []string{fmt.Sprintf("%d bus accesses, want %d", len(accesses), len(want))}
+	}
+	for i := range want {
+		if want[i] != accesses[i] {
+			return []string{fmt.Sprintf("bus access %d = %+v, want %+v", i, accesses[i], want[i])}
+		}
+	}
+	return nil
+}
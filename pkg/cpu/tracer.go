@@ -0,0 +1,98 @@
+package cpu
+
+import (
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/bokuweb/gopher-boy/pkg/types"
+)
+
+// Tracer writes executed instructions in gameboy-doctor log format.
+// https://github.com/robert/gameboy-doctor
+//
+//	A:01 F:B0 B:00 C:13 D:00 E:D8 H:01 L:4D SP:FFFE PC:0100 PCMEM:00,C3,13,02
+//
+// When w has Flush like bufio.Writer, it is flushed at the limit and by Flush.
+type Tracer struct {
+	mu       sync.Mutex
+	w        io.Writer
+	limit    uint
+	count    uint
+	from     types.Word
+	to       types.Word
+	filtered bool
+	// err is the error of flush at the limit, returned by Flush
+	err error
+}
+
+// NewTracer is Tracer constructor
+func NewTracer(w io.Writer) *Tracer {
+	return &Tracer{w: w}
+}
+
+// SetLimit stops tracing after n instructions are written.
+// 0 means unlimited.
+func (t *Tracer) SetLimit(n uint) {
+	t.limit = n
+}
+
+// SetPCRange traces only instructions whose PC is in [from, to].
+func (t *Tracer) SetPCRange(from, to types.Word) {
+	t.from = from
+	t.to = to
+	t.filtered = true
+}
+
+// Done reports whether the instruction limit has been reached.
+func (t *Tracer) Done() bool {
+	return t.limit != 0 && t.count >= t.limit
+}
+
+// Count returns the number of written instructions.
+func (t *Tracer) Count() uint {
+	return t.count
+}
+
+// Flush flushes the writer, it can be called from other goroutines.
+// It also returns the error of flush when the limit was reached.
+func (t *Tracer) Flush() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if err := t.flush(); err != nil {
+		return err
+	}
+	return t.err
+}
+
+func (t *Tracer) flush() error {
+	if f, ok := t.w.(interface{ Flush() error }); ok {
+		return f.Flush()
+	}
+	return nil
+}
+
+func (t *Tracer) trace(cpu *CPU) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.Done() {
+		return
+	}
+	if t.filtered && (cpu.PC < t.from || cpu.PC > t.to) {
+		return
+	}
+	// Peek not to run DMA or hit watchpoints before the instruction.
+	r := cpu.Regs
+	fmt.Fprintf(t.w,
+		"A:%02X F:%02X B:%02X C:%02X D:%02X E:%02X H:%02X L:%02X SP:%04X PC:%04X PCMEM:%02X,%02X,%02X,%02X\n",
+		r.A, r.F, r.B, r.C, r.D, r.E, r.H, r.L, cpu.SP, cpu.PC,
+		cpu.bus.Peek(cpu.PC),
+		cpu.bus.Peek(cpu.PC+1),
+		cpu.bus.Peek(cpu.PC+2),
+		cpu.bus.Peek(cpu.PC+3),
+	)
+	t.count++
+	if t.Done() {
+		t.err = t.flush()
+	}
+}
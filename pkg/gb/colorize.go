@@ -0,0 +1,240 @@
+package gb
+
+import (
+	"github.com/bokuweb/gopher-boy/pkg/gpu"
+	"github.com/bokuweb/gopher-boy/pkg/types"
+)
+
+// Cartridge header fields CGB boot ROM reads to colourise DMG games.
+const (
+	titleAddr       types.Word = 0x0134
+	titleSize                  = 16
+	newLicenseeAddr types.Word = 0x0144
+	oldLicenseeAddr types.Word = 0x014B
+	// useNewLicensee in old licensee code means new licensee code is used.
+	useNewLicensee byte = 0x33
+	nintendo       byte = 0x01
+)
+
+// titleKey identifies a title as CGB boot ROM does.
+// For checksums shared by several titles, the 4th letter of the title tells them apart,
+// otherwise letter is 0.
+type titleKey struct {
+	checksum byte
+	letter   byte
+}
+
+// titleCombinations is the title table of CGB boot ROM. It maps titles to
+// the palette combinations of gpu.CGBBootPalettes.
+var titleCombinations = []struct {
+	key         titleKey
+	combination int
+}{
+	{titleKey{0x00, 0}, 0},
+	{titleKey{0x88, 0}, 4},  // ALLEY WAY
+	{titleKey{0x16, 0}, 5},  // YAKUMAN
+	{titleKey{0x36, 0}, 35}, // BASEBALL
+	{titleKey{0xD1, 0}, 34}, // TENNIS
+	{titleKey{0xDB, 0}, 3},  // TETRIS
+	{titleKey{0xF2, 0}, 31}, // QIX
+	{titleKey{0x3C, 0}, 15}, // DR.MARIO
+	{titleKey{0x8C, 0}, 10}, // RADARMISSION
+	{titleKey{0x92, 0}, 5},  // F1RACE
+	{titleKey{0x3D, 0}, 19}, // YOSSY NO TAMAGO
+	{titleKey{0x5C, 0}, 36},
+	{titleKey{0x58, 0}, 7},  // X
+	{titleKey{0xC9, 0}, 37}, // MARIOLAND2
+	{titleKey{0x3E, 0}, 30}, // YOSSY NO COOKIE
+	{titleKey{0x70, 0}, 44}, // ZELDA
+	{titleKey{0x1D, 0}, 21},
+	{titleKey{0x59, 0}, 32},
+	{titleKey{0x69, 0}, 31}, // TETRIS FLASH
+	{titleKey{0x19, 0}, 20}, // DONKEY KONG
+	{titleKey{0x35, 0}, 5},  // MARIO'S PICROSS
+	{titleKey{0xA8, 0}, 33},
+	{titleKey{0x14, 0}, 13}, // POKEMON RED
+	{titleKey{0xAA, 0}, 14}, // POKEMON GREEN
+	{titleKey{0x75, 0}, 5},  // PICROSS 2
+	{titleKey{0x95, 0}, 29}, // YOSSY NO PANEPON
+	{titleKey{0x99, 0}, 5},  // KIRAKIRA KIDS
+	{titleKey{0x34, 0}, 18}, // GAMEBOY GALLERY
+	{titleKey{0x6F, 0}, 9},  // POCKETCAMERA
+	{titleKey{0x15, 0}, 3},
+	{titleKey{0xFF, 0}, 2},  // BALLOON KID
+	{titleKey{0x97, 0}, 26}, // KINGOFTHEZOO
+	{titleKey{0x4B, 0}, 25}, // DMG FOOTBALL
+	{titleKey{0x90, 0}, 25}, // WORLD CUP
+	{titleKey{0x17, 0}, 41}, // OTHELLO
+	{titleKey{0x10, 0}, 42}, // SUPER RC PRO-AM
+	{titleKey{0x39, 0}, 26}, // DYNABLASTER
+	{titleKey{0xF7, 0}, 45}, // BOY AND BLOB GB2
+	{titleKey{0xF6, 0}, 42}, // MEGAMAN
+	{titleKey{0xA2, 0}, 45}, // STAR WARS-NOA
+	{titleKey{0x49, 0}, 36},
+	{titleKey{0x4E, 0}, 38}, // WAVERACE
+	{titleKey{0x43, 0}, 26},
+	{titleKey{0x68, 0}, 42}, // LOLO2
+	{titleKey{0xE0, 0}, 30}, // YOSHI'S COOKIE
+	{titleKey{0x8B, 0}, 41}, // MYSTIC QUEST
+	{titleKey{0xF0, 0}, 34},
+	{titleKey{0xCE, 0}, 34}, // TOPRANKINGTENNIS
+	{titleKey{0x0C, 0}, 5},  // MANSELL
+	{titleKey{0x29, 0}, 42}, // MEGAMAN3
+	{titleKey{0xE8, 0}, 6},  // SPACE INVADERS
+	{titleKey{0xB7, 0}, 5},  // GAME&WATCH
+	{titleKey{0x86, 0}, 33}, // DONKEYKONGLAND95
+	{titleKey{0x9A, 0}, 25}, // ASTEROIDS/MISCMD
+	{titleKey{0x52, 0}, 42}, // STREET FIGHTER 2
+	{titleKey{0x01, 0}, 42}, // DEFENDER/JOUST
+	{titleKey{0x9D, 0}, 40}, // KILLERINSTINCT95
+	{titleKey{0x71, 0}, 2},  // TETRIS BLAST
+	{titleKey{0x9C, 0}, 16}, // PINOCCHIO
+	{titleKey{0xBD, 0}, 25},
+	{titleKey{0x5D, 0}, 42}, // BA.TOSHINDEN
+	{titleKey{0x6D, 0}, 42}, // NETTOU KOF 95
+	{titleKey{0x67, 0}, 5},
+	{titleKey{0x3F, 0}, 0},  // TETRIS PLUS
+	{titleKey{0x6B, 0}, 39}, // DONKEYKONGLAND 3
+	// Titles sharing a checksum are told apart by the 4th letter.
+	{titleKey{0xB3, 'B'}, 36},
+	{titleKey{0x46, 'E'}, 22}, // SUPER MARIOLAND
+	{titleKey{0x28, 'F'}, 25}, // GOLF
+	{titleKey{0xA5, 'A'}, 6},  // SOLARSTRIKER
+	{titleKey{0xC6, 'A'}, 32}, // GBWARS
+	{titleKey{0xD3, 'R'}, 12}, // KAERUNOTAMENI
+	{titleKey{0x27, 'B'}, 36},
+	{titleKey{0x61, 'E'}, 11}, // POKEMON BLUE
+	{titleKey{0x18, 'K'}, 39}, // DONKEYKONGLAND
+	{titleKey{0x66, 'E'}, 18}, // GAMEBOY GALLERY2
+	{titleKey{0x6A, 'K'}, 39}, // DONKEYKONGLAND 2
+	{titleKey{0xBF, ' '}, 24}, // KID ICARUS
+	{titleKey{0x0D, 'R'}, 31}, // TETRIS2
+	{titleKey{0xF4, '-'}, 50},
+	{titleKey{0xB3, 'U'}, 17}, // MOGURANYA
+	{titleKey{0x46, 'R'}, 46},
+	{titleKey{0x28, 'A'}, 6},  // GALAGA&GALAXIAN
+	{titleKey{0xA5, 'R'}, 27}, // BT2RAGNAROKWORLD
+	{titleKey{0xC6, ' '}, 0},  // KEN GRIFFEY JR
+	{titleKey{0xD3, 'I'}, 47},
+	{titleKey{0x27, 'N'}, 41}, // MAGNETIC SOCCER
+	{titleKey{0x61, 'A'}, 41}, // VEGAS STAKES
+	{titleKey{0x18, 'I'}, 0},
+	{titleKey{0x66, 'L'}, 0},  // MILLI/CENTI/PEDE
+	{titleKey{0x6A, 'I'}, 19}, // MARIO & YOSHI
+	{titleKey{0xBF, 'C'}, 34}, // SOCCER
+	{titleKey{0x0D, 'E'}, 23}, // POKEBOM
+	{titleKey{0xF4, ' '}, 18}, // G&W GALLERY
+	{titleKey{0xB3, 'R'}, 29}, // TETRIS ATTACK
+}
+
+// titlePalettes is palettes CGB boot ROM assigns to Nintendo titles.
+var titlePalettes = func() map[titleKey]gpu.Palettes {
+	m := make(map[titleKey]gpu.Palettes, len(titleCombinations))
+	for _, t := range titleCombinations {
+		m[t.key], _ = gpu.CGBBootPalettes(t.combination)
+	}
+	return m
+}()
+
+// WithColorize colourises DMG games with the palettes CGB boot ROM selects by title.
+// fallback is used for titles not in the table and games not licensed by Nintendo.
+func WithColorize(fallback gpu.Palettes) Option {
+	return func(g *GB) {
+		g.colorize = &fallback
+	}
+}
+
+// titleKey returns title checksum and the 4th letter of the title.
+// The checksum is sum of 16 bytes of the title.
+func (g *GB) titleKey() titleKey {
+	var k titleKey
+	for i := types.Word(0); i < titleSize; i++ {
+		k.checksum += g.bus.Peek(titleAddr + i)
+	}
+	k.letter = g.bus.Peek(titleAddr + 3)
+	return k
+}
+
+// licensedByNintendo reports whether licensee code in the header is Nintendo.
+func (g *GB) licensedByNintendo() bool {
+	old := g.bus.Peek(oldLicenseeAddr)
+	if old == useNewLicensee {
+		return g.bus.Peek(newLicenseeAddr) == '0' && g.bus.Peek(newLicenseeAddr+1) == '1'
+	}
+	return old == nintendo
+}
+
+// cartridgePalettes returns palettes assigned to the title.
+// Like CGB boot ROM, only games licensed by Nintendo are looked up.
+func (g *GB) cartridgePalettes() (gpu.Palettes, bool) {
+	if !g.licensedByNintendo() {
+		return gpu.Palettes{}, false
+	}
+	k := g.titleKey()
+	if p, ok := titlePalettes[k]; ok {
+		return p, true
+	}
+	p, ok := titlePalettes[titleKey{checksum: k.checksum}]
+	return p, ok
+}
+
+// colorizePalettes sets palettes of the title or the fallback.
+// Keys held at the end of boot logo select palettes even of games with assigned ones.
+func (g *GB) colorizePalettes(manual bool) {
+	if manual {
+		if p, ok := manualPalettes(g.readPad()); ok {
+			g.gpu.SetPalettes(p)
+			return
+		}
+	}
+	if p, ok := g.cartridgePalettes(); ok {
+		g.gpu.SetPalettes(p)
+		return
+	}
+	g.gpu.SetPalettes(*g.colorize)
+}
+
+// Pad bits read from 0xFF00, they are 0 while pressed.
+const (
+	padRight byte = 0x01
+	padLeft  byte = 0x02
+	padUp    byte = 0x04
+	padDown  byte = 0x08
+	padA     byte = 0x01
+	padB     byte = 0x02
+)
+
+// manualPalettes returns the palettes selected by holding a direction and
+// optionally A or B, as on CGB boot logo.
+func manualPalettes(directions, buttons byte) (gpu.Palettes, bool) {
+	var name string
+	switch {
+	case directions&padUp == 0:
+		name = "cgb-up"
+	case directions&padLeft == 0:
+		name = "cgb-left"
+	case directions&padDown == 0:
+		name = "cgb-down"
+	case directions&padRight == 0:
+		name = "cgb-right"
+	default:
+		return gpu.Palettes{}, false
+	}
+	switch {
+	case buttons&padA == 0:
+		name += "-a"
+	case buttons&padB == 0:
+		name += "-b"
+	}
+	return gpu.PalettePreset(name)
+}
+
+// readPad reads direction keys and buttons through the joypad register as boot ROM does.
+func (g *GB) readPad() (directions, buttons byte) {
+	g.bus.Poke(0xFF00, 0x20)
+	directions = g.bus.Peek(0xFF00)
+	g.bus.Poke(0xFF00, 0x10)
+	buttons = g.bus.Peek(0xFF00)
+	g.bus.Poke(0xFF00, 0x30)
+	return directions, buttons
+}
@@ -0,0 +1,105 @@
+package gb
+
+import (
+	"github.com/bokuweb/gopher-boy/pkg/bus"
+	"github.com/bokuweb/gopher-boy/pkg/cpu"
+	"github.com/bokuweb/gopher-boy/pkg/types"
+)
+
+// AnyBank matches every ROM bank
+const AnyBank = -1
+
+// Breakpoint stops Next before the instruction at PC is executed.
+type Breakpoint struct {
+	PC types.Word
+	// Bank is ROM bank mapped at PC, AnyBank matches every bank.
+	Bank int
+	// Cond is optional condition evaluated when PC matches.
+	Cond func(c *cpu.CPU) bool
+	// HitCount triggers the breakpoint on N-th hit and after. 0 means first hit.
+	HitCount uint
+	// Hits is number of times PC and conditions matched.
+	Hits uint
+	id   int
+}
+
+// NewBreakpoint is Breakpoint constructor matches every bank.
+func NewBreakpoint(pc types.Word) *Breakpoint {
+	return &Breakpoint{PC: pc, Bank: AnyBank}
+}
+
+// StopKind is why Next returned before the frame completed
+type StopKind int
+
+const (
+	// StopBreakpoint means a breakpoint is hit
+	StopBreakpoint StopKind = iota + 1
+	// StopWatchpoint means a watchpoint is hit
+	StopWatchpoint
+)
+
+// StopReason is reported by StopReason after Next returned early.
+type StopReason struct {
+	Kind StopKind
+	// PC is program counter after stopped
+	PC types.Word
+	// Breakpoint is id of hit breakpoint
+	Breakpoint int
+	// Watch is hit watchpoint
+	Watch *bus.WatchHit
+}
+
+// AddBreakpoint adds breakpoint and returns its id.
+func (g *GB) AddBreakpoint(bp *Breakpoint) int {
+	g.breakpointID++
+	bp.id = g.breakpointID
+	g.breakpoints = append(g.breakpoints, bp)
+	return bp.id
+}
+
+// RemoveBreakpoint removes breakpoint by id.
+func (g *GB) RemoveBreakpoint(id int) {
+	for i, bp := range g.breakpoints {
+		if bp.id == id {
+			g.breakpoints = append(g.breakpoints[:i], g.breakpoints[i+1:]...)
+			return
+		}
+	}
+}
+
+// AddWatchpoint adds bus watchpoint and returns its id.
+func (g *GB) AddWatchpoint(w bus.Watchpoint) int {
+	return g.bus.AddWatchpoint(w)
+}
+
+// RemoveWatchpoint removes bus watchpoint by id.
+func (g *GB) RemoveWatchpoint(id int) {
+	g.bus.RemoveWatchpoint(id)
+}
+
+// StopReason returns why last Next returned early.
+// nil means last Next completed the frame.
+func (g *GB) StopReason() *StopReason {
+	return g.stopReason
+}
+
+func (g *GB) checkBreakpoints() *StopReason {
+	pc := g.cpu.PC
+	for _, bp := range g.breakpoints {
+		if bp.PC != pc {
+			continue
+		}
+		if bp.Bank != AnyBank && bp.Bank != g.bus.ROMBank(pc) {
+			continue
+		}
+		if bp.Cond != nil && !bp.Cond(g.cpu) {
+			continue
+		}
+		bp.Hits++
+		if bp.Hits < bp.HitCount {
+			continue
+		}
+		return &StopReason{Kind: StopBreakpoint, PC: pc, Breakpoint: bp.id}
+	}
+	return nil
+}
@@ -3,10 +3,12 @@ package gb
 import (
 	"time"
 
+	"github.com/bokuweb/gopher-boy/pkg/bus"
 	"github.com/bokuweb/gopher-boy/pkg/cpu"
 	"github.com/bokuweb/gopher-boy/pkg/gpu"
 	"github.com/bokuweb/gopher-boy/pkg/interfaces/window"
 	"github.com/bokuweb/gopher-boy/pkg/interrupt"
+	"github.com/bokuweb/gopher-boy/pkg/sgb"
 	"github.com/bokuweb/gopher-boy/pkg/timer"
 )
 
@@ -16,56 +18,131 @@ const CyclesPerFrame = 70224
 // GB is gameboy emulator struct
 type GB struct {
 	currentCycle uint
+	bus          *bus.Bus
 	cpu          *cpu.CPU
 	gpu          *gpu.GPU
 	timer        *timer.Timer
 	irq          *interrupt.Interrupt
 	win          window.Window
+	model        Model
+	boot         *hleBoot
+	// colorize is fallback palettes when DMG games are colourised
+	colorize *gpu.Palettes
+	sgb      *sgb.SGB
+	// sgbBoot is true while SGB boot ROM is mapped
+	sgbBoot bool
+
+	breakpointID int
+	breakpoints  []*Breakpoint
+	stopReason   *StopReason
+	// resumed skips breakpoints at current PC after stopped
+	resumed bool
 }
 
 // NewGB is gb initializer
-func NewGB(cpu *cpu.CPU, gpu *gpu.GPU, timer *timer.Timer, irq *interrupt.Interrupt, win window.Window) *GB {
-	return &GB{
+// The emulator starts with post-boot state of the model, see WithModel.
+func NewGB(bus *bus.Bus, cpu *cpu.CPU, gpu *gpu.GPU, timer *timer.Timer, irq *interrupt.Interrupt, win window.Window, opts ...Option) *GB {
+	g := &GB{
 		currentCycle: 0,
+		bus:          bus,
 		cpu:          cpu,
 		gpu:          gpu,
 		timer:        timer,
 		irq:          irq,
 		win:          win,
+		model:        DMG,
+	}
+	for _, opt := range opts {
+		opt(g)
+	}
+	g.gpu.SetCGBHardware(g.model.cgb())
+	g.skipBoot(false)
+	return g
+}
+
+// LoadBootROM maps DMG/MGB/SGB/CGB boot ROM and runs it from 0x0000.
+// Without boot ROM, emulator starts from 0x0100 with post-boot state.
+func (g *GB) LoadBootROM(buf []byte) error {
+	if err := g.bus.SetBootROM(buf); err != nil {
+		return err
+	}
+	g.boot = nil
+	g.cpu.PowerOn()
+	g.gpu.PowerOn()
+	// The boot ROM tells the hardware regardless of the model.
+	g.gpu.SetCGBHardware(len(buf) == bus.CGBBootROMSize)
+	g.timer.PowerOn()
+	g.irq.PowerOn()
+	if g.sgb != nil {
+		g.sgb.SetEnabled(false)
+		g.sgbBoot = true
 	}
+	return nil
 }
 
-// Start is
+// ScreenBlank reports whether LCD shows a blank screen, see GPU.ScreenBlank.
+func (g *GB) ScreenBlank() bool {
+	return g.gpu.ScreenBlank()
+}
+
+// Start runs the emulator until the window is closed.
 func (g *GB) Start() {
 	t := time.NewTicker(16 * time.Millisecond)
+	defer t.Stop()
 	for {
 		select {
 		case <-t.C:
+			if g.win.Closed() {
+				return
+			}
 			buf := g.Next()
 			g.win.Render(buf)
 		}
 	}
-	t.Stop()
 }
+
+// Next runs emulator until a frame is completed and returns the image data.
+// Next returns early when a breakpoint or watchpoint is hit, see StopReason.
 func (g *GB) Next() []byte {
+	g.stopReason = nil
 	for {
 		var cycles uint
-		if g.gpu.DMAStarted() {
-			g.gpu.Transfer()
-			// https://github.com/Gekkio/mooneye-gb/blob/master/docs/accuracy.markdown#how-many-cycles-does-oam-dma-take
-			cycles = 162
+		if g.boot != nil {
+			cycles = g.stepBoot()
+		} else if stall := g.bus.StepHDMA(); stall != 0 {
+			// CPU is stalled while VRAM DMA copies blocks.
+			cycles = stall
 		} else {
+			if len(g.breakpoints) != 0 && !g.resumed && !g.cpu.Halted() {
+				if r := g.checkBreakpoints(); r != nil {
+					g.stopReason = r
+					g.resumed = true
+					return g.image()
+				}
+			}
+			g.resumed = false
+			g.bus.BeginStep()
 			cycles = g.cpu.Step()
 		}
-		g.gpu.Step(cycles * 4)
-		if overflowed := g.timer.Update(cycles); overflowed {
-			g.irq.SetIRQ(interrupt.TimerOverflowFlag)
+		// PPU runs at the same rate in CGB double speed mode.
+		dots := cycles * 4
+		if g.bus.DoubleSpeed() {
+			dots = cycles * 2
+		}
+		g.bus.EndStep(cycles)
+		g.currentCycle += dots
+		if g.sgbBoot {
+			g.endSGBBoot()
+		}
+		if hit := g.bus.WatchHit(); hit != nil {
+			g.stopReason = &StopReason{Kind: StopWatchpoint, PC: g.cpu.PC, Watch: hit}
+			return g.image()
 		}
-		g.currentCycle += cycles * 4
 		if g.currentCycle >= CyclesPerFrame {
 			g.win.PollKey()
 			g.currentCycle -= CyclesPerFrame
-			return g.gpu.GetImageData()
+			g.endFrame()
+			return g.image()
 		}
 	}
 }
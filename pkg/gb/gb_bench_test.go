@@ -0,0 +1,19 @@
+package gb
+
+import (
+	"testing"
+	"time"
+)
+
+// go test -run none -bench . -benchmem ./pkg/gb
+// ns/op, B/op and allocs/op are per frame.
+func BenchmarkCPUInstrs(b *testing.B) {
+	emu := setup(RomPathPrefix + "cpu_instrs/cpu_instrs.gb")
+	b.ReportAllocs()
+	b.ResetTimer()
+	start := time.Now()
+	for i := 0; i < b.N; i++ {
+		emu.Next()
+	}
+	b.ReportMetric(float64(b.N)/time.Since(start).Seconds(), "frames/s")
+}
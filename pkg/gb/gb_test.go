@@ -16,10 +16,14 @@ import (
 	"github.com/bokuweb/gopher-boy/pkg/cartridge"
 	"github.com/bokuweb/gopher-boy/pkg/cpu"
 	"github.com/bokuweb/gopher-boy/pkg/gpu"
+	padif "github.com/bokuweb/gopher-boy/pkg/interfaces/pad"
 	"github.com/bokuweb/gopher-boy/pkg/interfaces/window"
 	"github.com/bokuweb/gopher-boy/pkg/logger"
 	"github.com/bokuweb/gopher-boy/pkg/ram"
+	"github.com/bokuweb/gopher-boy/pkg/sgb"
+	"github.com/bokuweb/gopher-boy/pkg/types"
 	"github.com/bokuweb/gopher-boy/pkg/utils"
+	"github.com/stretchr/testify/assert"
 )
 
 const (
@@ -36,11 +40,37 @@ func (m mockWindow) PollKey() {
 }
 
 func setup(file string) *GB {
-	l := logger.NewLogger(logger.LogLevel("DEBUG"))
+	return setupWith(file)
+}
+
+func setupWith(file string, opts ...Option) *GB {
 	buf, err := utils.LoadROM(file)
 	if err != nil {
 		panic(err)
 	}
+	return setupROM(buf, opts...)
+}
+
+func setupROM(buf []byte, opts ...Option) *GB {
+	emu, _ := setupROMWithPad(buf, opts...)
+	return emu
+}
+
+// setupROMWithPad returns pad of the emulator too for tests holding keys.
+func setupROMWithPad(buf []byte, opts ...Option) (*GB, *pad.Pad) {
+	p := pad.NewPad()
+	return setupROMOn(buf, p, opts...), p
+}
+
+// setupSGB runs buf on SGB.
+func setupSGB(buf []byte, opts ...Option) (*GB, *sgb.SGB) {
+	s := sgb.NewSGB(pad.NewPad())
+	opts = append([]Option{WithModel(SGB), WithSGB(s)}, opts...)
+	return setupROMOn(buf, s, opts...), s
+}
+
+func setupROMOn(buf []byte, pad padif.Pad, opts ...Option) *GB {
+	l := logger.NewLogger(logger.LogLevel("DEBUG"))
 	cart, err := cartridge.NewCartridge(buf)
 	if err != nil {
 		panic(err)
@@ -52,12 +82,11 @@ func setup(file string) *GB {
 	oamRAM := ram.NewRAM(0xA0)
 	gpu := gpu.NewGPU()
 	t := timer.NewTimer()
-	pad := pad.NewPad()
 	irq := interrupt.NewInterrupt()
 	b := bus.NewBus(l, cart, gpu, vRAM, wRAM, hRAM, oamRAM, t, irq, pad)
 	gpu.Init(b, irq)
 	win := mockWindow{}
-	emu := NewGB(cpu.NewCPU(l, b, irq), gpu, t, irq, win)
+	emu := NewGB(b, cpu.NewCPU(l, b, irq), gpu, t, irq, win, opts...)
 	return emu
 }
 
@@ -185,3 +214,479 @@ func TestROMs(t *testing.T) {
 		})
 	}
 }
+
+func TestBreakpoint(t *testing.T) {
+	assert := assert.New(t)
+	emu := setup(RomPathPrefix + "helloworld/hello.gb")
+	id := emu.AddBreakpoint(NewBreakpoint(0x0100))
+	emu.Next()
+	assert.Equal(&StopReason{Kind: StopBreakpoint, PC: 0x0100, Breakpoint: id}, emu.StopReason())
+	emu.Next()
+	assert.Nil(emu.StopReason())
+	emu.RemoveBreakpoint(id)
+
+	bp := NewBreakpoint(0x0100)
+	bp.Cond = func(c *cpu.CPU) bool { return c.Regs.A == 0xFF }
+	emu.AddBreakpoint(bp)
+	emu.Next()
+	assert.Nil(emu.StopReason())
+}
+
+func TestWatchpoint(t *testing.T) {
+	assert := assert.New(t)
+	emu := setup(RomPathPrefix + "helloworld/hello.gb")
+	id := emu.AddWatchpoint(bus.Watchpoint{Start: 0xFF40, End: 0xFF40, Kind: bus.WatchWrite})
+	emu.Next()
+	r := emu.StopReason()
+	assert.Equal(StopWatchpoint, r.Kind)
+	assert.Equal(id, r.Watch.ID)
+	assert.Equal(types.Word(0xFF40), r.Watch.Addr)
+}
+
+func TestBootROM(t *testing.T) {
+	assert := assert.New(t)
+	emu := setup(RomPathPrefix + "helloworld/hello.gb")
+	boot := make([]byte, bus.DMGBootROMSize)
+	// JP $00FC
+	copy(boot[0x00:], []byte{0xC3, 0xFC, 0x00})
+	// LD A,$01 / LDH ($50),A
+	copy(boot[0xFC:], []byte{0x3E, 0x01, 0xE0, 0x50})
+	assert.NoError(emu.LoadBootROM(boot))
+	assert.Equal(types.Word(0x0000), emu.cpu.PC)
+	// Post-boot state of skipped boot is reset to power-on values.
+	assert.Equal(byte(0x00), emu.bus.ReadByte(0xFF04))
+	assert.Equal(byte(0xE0), emu.bus.ReadByte(0xFF0F))
+	assert.Equal(byte(0x00), emu.bus.ReadByte(0xFF47))
+	assert.Equal(byte(0x70), emu.bus.ReadByte(0xFF26))
+	emu.AddBreakpoint(NewBreakpoint(0x0100))
+	emu.Next()
+	assert.Equal(StopBreakpoint, emu.StopReason().Kind)
+	assert.False(emu.bus.BootROMMapped())
+	assert.Equal(byte(0x01), emu.cpu.Regs.A)
+}
+
+func TestSGBBootROM(t *testing.T) {
+	assert := assert.New(t)
+	p := &program{}
+	// MLT_REQ for 2 players.
+	p.sgbPacket(0x11<<3|1, 0x01)
+	buf := p.rom()
+	buf[0x146] = 0x03
+	buf[0x14B] = 0x33
+	emu, s := setupSGB(buf)
+	boot := make([]byte, bus.DMGBootROMSize)
+	// JP $00FC
+	copy(boot[0x00:], []byte{0xC3, 0xFC, 0x00})
+	// LD A,$01 / LDH ($50),A
+	copy(boot[0xFC:], []byte{0x3E, 0x01, 0xE0, 0x50})
+	assert.NoError(emu.LoadBootROM(boot))
+	// Packets from the cartridge are accepted after boot ROM is unmapped.
+	skipFrame(emu, 2)
+	assert.False(emu.bus.BootROMMapped())
+	assert.Equal(2, s.Players())
+}
+
+func TestModelBootRegs(t *testing.T) {
+	tests := []struct {
+		path  string
+		model Model
+	}{
+		{"acceptance/boot_regs-dmg0.gb", DMG0},
+		{"acceptance/boot_regs-dmgABC.gb", DMG},
+		{"acceptance/boot_regs-mgb.gb", MGB},
+		{"acceptance/boot_regs-sgb.gb", SGB},
+		{"acceptance/boot_regs-sgb2.gb", SGB2},
+		{"acceptance/boot_div-dmg0.gb", DMG0},
+		{"acceptance/boot_div-dmgABCmgb.gb", DMG},
+		{"acceptance/boot_div-dmgABCmgb.gb", MGB},
+		{"acceptance/boot_div-S.gb", SGB},
+		{"acceptance/boot_div-S.gb", SGB2},
+		{"acceptance/boot_hwio-dmg0.gb", DMG0},
+		{"acceptance/boot_hwio-dmgABCmgb.gb", DMG},
+		{"acceptance/boot_hwio-dmgABCmgb.gb", MGB},
+		{"acceptance/boot_hwio-S.gb", SGB},
+		{"acceptance/boot_hwio-S.gb", SGB2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path+"/"+tt.model.String(), func(t *testing.T) {
+			emu := setupWith(RomPathPrefix+tt.path, WithModel(tt.model))
+			assert.Equal(t, tt.model, emu.Model())
+			skipFrame(emu, 60)
+			assertMooneyePassed(t, emu)
+		})
+	}
+}
+
+func TestModelBootRegsCGB(t *testing.T) {
+	tests := []struct {
+		model Model
+		cgb   bool
+		regs  cpu.Registers
+		key1  byte
+	}{
+		{CGB, true, cpu.Registers{A: 0x11, F: 0x80, B: 0x00, C: 0x00, D: 0xFF, E: 0x56, H: 0x00, L: 0x0D}, 0x7E},
+		{CGB, false, cpu.Registers{A: 0x11, F: 0x80, B: 0x00, C: 0x00, D: 0x00, E: 0x08, H: 0x00, L: 0x7C}, 0xFF},
+		{AGB, true, cpu.Registers{A: 0x11, F: 0x00, B: 0x01, C: 0x00, D: 0xFF, E: 0x56, H: 0x00, L: 0x0D}, 0x7E},
+	}
+	for _, tt := range tests {
+		t.Run(tt.model.String(), func(t *testing.T) {
+			assert := assert.New(t)
+			p := &program{}
+			p.jr(len(*p))
+			buf := p.rom()
+			if tt.cgb {
+				buf[0x143] = 0x80
+			}
+			emu := setupROM(buf, WithModel(tt.model))
+			assert.Equal(tt.regs, emu.cpu.Regs)
+			assert.Equal(byte(0x1E), emu.bus.ReadByte(0xFF04))
+			assert.Equal(byte(0x90), emu.bus.ReadByte(0xFF44))
+			assert.Equal(byte(0xCF), emu.bus.ReadByte(0xFF00))
+			assert.Equal(byte(0x7F), emu.bus.ReadByte(0xFF02))
+			assert.Equal(byte(0xF1), emu.bus.ReadByte(0xFF26))
+			assert.Equal(tt.key1, emu.bus.ReadByte(0xFF4D))
+		})
+	}
+}
+
+// assertMooneyePassed checks registers which mooneye test ROMs set on success.
+func assertMooneyePassed(t *testing.T, emu *GB) {
+	r := emu.cpu.Regs
+	// Mooneye test suite passes with fibonacci numbers.
+	assert.Equal(t, []byte{3, 5, 8, 13, 21, 34}, []byte{r.B, r.C, r.D, r.E, r.H, r.L})
+}
+
+func TestMooneye(t *testing.T) {
+	tests := []string{
+		"acceptance/ppu/stat_irq_blocking.gb",
+		"acceptance/ppu/intr_2_mode0_timing.gb",
+		"acceptance/ppu/intr_2_mode3_timing.gb",
+		"acceptance/ppu/intr_2_oam_ok_timing.gb",
+		"acceptance/ppu/vblank_stat_intr-GS.gb",
+		"acceptance/ppu/intr_1_2_timing-GS.gb",
+		"acceptance/ppu/intr_2_0_timing.gb",
+		"acceptance/ppu/hblank_ly_scx_timing-GS.gb",
+		"acceptance/ppu/lcdon_timing-dmgABCmgbS.gb",
+		"acceptance/ppu/lcdon_write_timing-GS.gb",
+		"acceptance/ppu/stat_lyc_onoff.gb",
+		"acceptance/oam_dma_start.gb",
+		"acceptance/oam_dma_restart.gb",
+		"acceptance/oam_dma_timing.gb",
+		"acceptance/oam_dma/basic.gb",
+		"acceptance/oam_dma/reg_read.gb",
+		"acceptance/add_sp_e_timing.gb",
+		"acceptance/ld_hl_sp_e_timing.gb",
+		"acceptance/call_timing.gb",
+		"acceptance/call_cc_timing.gb",
+		"acceptance/jp_timing.gb",
+		"acceptance/jp_cc_timing.gb",
+		"acceptance/ret_timing.gb",
+		"acceptance/reti_timing.gb",
+		"acceptance/intr_timing.gb",
+		"acceptance/halt_ime0_nointr_timing.gb",
+	}
+	for _, path := range tests {
+		t.Run(path, func(t *testing.T) {
+			emu := setup(RomPathPrefix + path)
+			skipFrame(emu, 120)
+			assertMooneyePassed(t, emu)
+		})
+	}
+}
+
+// intr_2_mode0_timing_sprites measures many sprite layouts and needs more frames.
+func TestMooneyeSprites(t *testing.T) {
+	emu := setup(RomPathPrefix + "acceptance/ppu/intr_2_mode0_timing_sprites.gb")
+	skipFrame(emu, 300)
+	assertMooneyePassed(t, emu)
+}
+
+func TestParseModel(t *testing.T) {
+	assert := assert.New(t)
+	m, ok := ParseModel("CGB")
+	assert.True(ok)
+	assert.Equal(CGB, m)
+	_, ok = ParseModel("NES")
+	assert.False(ok)
+}
+
+func TestBootHLE(t *testing.T) {
+	assert := assert.New(t)
+	emu := setup(RomPathPrefix + "helloworld/hello.gb")
+	assert.NoError(emu.BootHLE(true))
+	assert.True(emu.Booting())
+	assert.Equal(byte(0x01), emu.bus.ReadByte(0x9904))
+	assert.Equal(byte(0x19), emu.bus.ReadByte(0x9910))
+	// Top left row of the first logo tile is 0xCE upper nibble doubled.
+	assert.Equal(byte(0xF0), emu.bus.ReadByte(0x8010))
+	skipFrame(emu, 10)
+	assert.Equal(byte(hleScrollStart-10), emu.gpu.Read(gpu.SCROLLY))
+	skipFrame(emu, hleBootFrames)
+	assert.False(emu.Booting())
+	assert.NotEqual(types.Word(0x0000), emu.cpu.PC)
+}
+
+func TestBootHLEBypassesWatchpoints(t *testing.T) {
+	assert := assert.New(t)
+	emu := setup(RomPathPrefix + "helloworld/hello.gb")
+	emu.AddWatchpoint(bus.Watchpoint{Start: 0x0104, End: 0x9FFF, Kind: bus.WatchRead | bus.WatchWrite})
+	assert.NoError(emu.BootHLE(true))
+	assert.Nil(emu.bus.WatchHit())
+	assert.Equal(byte(0xF0), emu.bus.Peek(0x8010))
+}
+
+func TestBootHLEInvalidHeader(t *testing.T) {
+	assert := assert.New(t)
+	buf, err := utils.LoadROM(RomPathPrefix + "helloworld/hello.gb")
+	assert.NoError(err)
+	buf[0x014D]++
+	emu := setupROM(buf)
+	assert.Equal(ErrInvalidHeaderChecksum, emu.BootHLE(true))
+	skipFrame(emu, hleBootFrames+10)
+	assert.True(emu.Booting())
+
+	emu = setupROM(buf)
+	assert.Equal(ErrInvalidHeaderChecksum, emu.BootHLE(false))
+	skipFrame(emu, hleBootFrames+10)
+	assert.False(emu.Booting())
+
+	buf[0x0104] = 0x00
+	emu = setupROM(buf)
+	assert.Equal(ErrInvalidLogo, emu.BootHLE(false))
+}
+
+// program assembles a small ROM which runs from 0x0150.
+type program []byte
+
+// waitLY waits until LY becomes ly.
+func (p *program) waitLY(ly byte) {
+	// LDH A,($44) / CP ly / JR NZ,-6
+	*p = append(*p, 0xF0, 0x44, 0xFE, ly, 0x20, 0xFA)
+}
+
+// ldh writes v to $FF00+addr.
+func (p *program) ldh(addr, v byte) {
+	// LD A,v / LDH (addr),A
+	*p = append(*p, 0x3E, v, 0xE0, addr)
+}
+
+// fill writes v to n bytes from addr.
+func (p *program) fill(addr uint16, v, n byte) {
+	// LD HL,addr / LD A,v / LD B,n / LD (HL+),A / DEC B / JR NZ,-4
+	*p = append(*p, 0x21, byte(addr), byte(addr>>8), 0x3E, v, 0x06, n, 0x22, 0x05, 0x20, 0xFC)
+}
+
+// jr jumps to offset in the program.
+func (p *program) jr(offset int) {
+	*p = append(*p, 0x18, byte(offset-len(*p)-2))
+}
+
+// sgbPacket sends a packet of data through P14 and P15.
+func (p *program) sgbPacket(data ...byte) {
+	buf := make([]byte, 16)
+	copy(buf, data)
+	p.ldh(0x00, 0x00)
+	p.ldh(0x00, 0x30)
+	for i := 0; i < len(buf)*8; i++ {
+		if buf[i/8]>>uint(i%8)&0x01 != 0 {
+			p.ldh(0x00, 0x10)
+		} else {
+			p.ldh(0x00, 0x20)
+		}
+		p.ldh(0x00, 0x30)
+	}
+	p.ldh(0x00, 0x20)
+	p.ldh(0x00, 0x30)
+}
+
+func (p program) rom() []byte {
+	buf := make([]byte, 0x8000)
+	// JP $0150
+	copy(buf[0x100:], []byte{0xC3, 0x50, 0x01})
+	copy(buf[0x150:], p)
+	return buf
+}
+
+// windowProgram sets up the window which shows tile 1 (colour 3) over BG of colour 0.
+func windowProgram() *program {
+	p := &program{}
+	p.waitLY(0x90)
+	p.ldh(0x40, 0x00)
+	p.fill(0x8010, 0xFF, 16)
+	p.fill(0x9C00, 0x01, 0x80)
+	p.ldh(0x47, 0xE4)
+	p.ldh(0x4A, 0)
+	p.ldh(0x4B, 7)
+	p.ldh(0x40, 0xF1)
+	return p
+}
+
+// shadeAt returns RGBA of the pixel.
+func shadeAt(img []byte, x, y int) []byte {
+	base := (y*constants.ScreenWidth + x) * 4
+	return img[base : base+4]
+}
+
+func TestWindowLineCounterROM(t *testing.T) {
+	assert := assert.New(t)
+	p := windowProgram()
+	// Window map row 1 is tile 0.
+	p.fill(0x9C20, 0x00, 0x20)
+	loop := len(*p)
+	p.waitLY(4)
+	p.ldh(0x40, 0xD1)
+	p.waitLY(12)
+	p.ldh(0x40, 0xF1)
+	p.waitLY(0x90)
+	p.jr(loop)
+	emu := setupROM(p.rom())
+	img := skipFrame(emu, 10)
+	// The window is drawn on line 13 with its row 5 or 6, not row 13.
+	assert.Equal(shadeAt(img, 0, 0), shadeAt(img, 0, 13))
+	assert.NotEqual(shadeAt(img, 0, 0), shadeAt(img, 0, 8))
+}
+
+func TestWindowWYROM(t *testing.T) {
+	assert := assert.New(t)
+	p := windowProgram()
+	loop := len(*p)
+	p.ldh(0x4A, 50)
+	p.waitLY(30)
+	// Moving WY above LY does not show the window.
+	p.ldh(0x4A, 10)
+	p.waitLY(45)
+	p.ldh(0x4A, 50)
+	p.waitLY(0x90)
+	p.jr(loop)
+	emu := setupROM(p.rom())
+	img := skipFrame(emu, 10)
+	assert.NotEqual(shadeAt(img, 0, 60), shadeAt(img, 0, 40))
+	assert.Equal(shadeAt(img, 0, 60), shadeAt(img, 0, 55))
+}
+
+// setTitle writes title to the header of buf.
+func setTitle(buf []byte, title string) {
+	copy(buf[titleAddr:titleAddr+titleSize], make([]byte, titleSize))
+	copy(buf[titleAddr:], title)
+}
+
+func TestColorize(t *testing.T) {
+	assert := assert.New(t)
+	fallback, _ := gpu.PalettePreset("pocket")
+	buf := program{}.rom()
+	buf[0x14B] = 0x01
+	setTitle(buf, "TETRIS")
+	emu := setupROM(buf, WithColorize(fallback))
+	assert.Equal(titleKey{checksum: 0xDB, letter: 'R'}, emu.titleKey())
+	tetris, _ := gpu.PalettePreset("cgb-down-a")
+	assert.Equal(tetris, emu.gpu.Palettes())
+
+	setTitle(buf, "POKEMON RED")
+	emu = setupROM(buf, WithColorize(fallback))
+	assert.Equal(titleKey{checksum: 0x14, letter: 'E'}, emu.titleKey())
+	p := emu.gpu.Palettes()
+	assert.Equal(color.RGBA{0xFF, 0x84, 0x84, 0xFF}, p.BG[1])
+	assert.Equal(color.RGBA{0x94, 0x39, 0x39, 0xFF}, p.BG[2])
+	assert.Equal(color.RGBA{0x7B, 0xFF, 0x31, 0xFF}, p.OBP0[1])
+	assert.Equal(p.BG, p.OBP1)
+
+	// Titles of the same checksum are told apart by the 4th letter.
+	setTitle(buf, "SUPER MARIOLAND")
+	emu = setupROM(buf, WithColorize(fallback))
+	assert.Equal(titleKey{checksum: 0x46, letter: 'E'}, emu.titleKey())
+	mario, _ := gpu.CGBBootPalettes(22)
+	assert.Equal(mario, emu.gpu.Palettes())
+	setTitle(buf, "SUPRE MARIOLAND")
+	emu = setupROM(buf, WithColorize(fallback))
+	other, _ := gpu.CGBBootPalettes(46)
+	assert.Equal(other, emu.gpu.Palettes())
+	setTitle(buf, "SUEPR MARIOLAND")
+	emu = setupROM(buf, WithColorize(fallback))
+	assert.Equal(fallback, emu.gpu.Palettes())
+
+	// Games not licensed by Nintendo are not looked up.
+	setTitle(buf, "TETRIS")
+	buf[0x14B] = 0x33
+	copy(buf[0x144:], "02")
+	emu = setupROM(buf, WithColorize(fallback))
+	assert.Equal(fallback, emu.gpu.Palettes())
+	copy(buf[0x144:], "01")
+	emu = setupROM(buf, WithColorize(fallback))
+	assert.Equal(tetris, emu.gpu.Palettes())
+
+	// Keys held at the end of boot override palettes of the title.
+	emu, p1 := setupROMWithPad(buf, WithColorize(fallback))
+	p1.Press(pad.Left)
+	p1.Press(pad.B)
+	emu.skipBoot(true)
+	grey, _ := gpu.PalettePreset("cgb-left-b")
+	assert.Equal(grey, emu.gpu.Palettes())
+	emu.skipBoot(false)
+	assert.Equal(tetris, emu.gpu.Palettes())
+}
+
+func TestManualPalettes(t *testing.T) {
+	assert := assert.New(t)
+	grey, _ := gpu.PalettePreset("cgb-left-b")
+	p, ok := manualPalettes(0x0F&^padLeft, 0x0F&^padB)
+	assert.True(ok)
+	assert.Equal(grey, p)
+	_, ok = manualPalettes(0x0F, 0x0F&^padA)
+	assert.False(ok)
+}
+
+func TestCGBProgram(t *testing.T) {
+	assert := assert.New(t)
+	p := &program{}
+	p.waitLY(0x90)
+	p.ldh(0x40, 0x00)
+	// Colour 0 of BG palette 0 is red and of BG palette 1 is green.
+	p.ldh(0x68, 0x80)
+	p.ldh(0x69, 0x1F)
+	p.ldh(0x69, 0x00)
+	p.ldh(0x68, 0x88)
+	p.ldh(0x69, 0xE0)
+	p.ldh(0x69, 0x03)
+	// The first row of the BG map uses palette 1.
+	p.ldh(0x4F, 0x01)
+	p.fill(0x9800, 0x01, 32)
+	p.ldh(0x4F, 0x00)
+	p.ldh(0x40, 0x91)
+	p.jr(len(*p))
+	buf := p.rom()
+	buf[0x143] = 0x80
+	assert.Equal(CGB, ROMModel(buf))
+	emu := setupROM(buf, WithModel(ROMModel(buf)))
+	img := skipFrame(emu, 5)
+	assert.Equal([]byte{0x00, 0xFF, 0x00, 0xFF}, shadeAt(img, 0, 0))
+	assert.Equal([]byte{0xFF, 0x00, 0x00, 0xFF}, shadeAt(img, 0, 8))
+
+	// DMG cartridges run on DMG.
+	buf[0x143] = 0x00
+	assert.Equal(DMG, ROMModel(buf))
+}
+
+func TestDoubleSpeed(t *testing.T) {
+	assert := assert.New(t)
+	p := &program{}
+	p.ldh(0x4D, 0x01)
+	// STOP
+	*p = append(*p, 0x10, 0x00)
+	// Count TIMA at 4096Hz of the CPU clock for a frame.
+	p.waitLY(0x90)
+	p.ldh(0x05, 0x00)
+	p.ldh(0x07, 0x04)
+	p.waitLY(0x00)
+	p.waitLY(0x90)
+	// LDH A,($05) / LDH ($80),A / LDH A,($4D) / LDH ($81),A
+	*p = append(*p, 0xF0, 0x05, 0xE0, 0x80, 0xF0, 0x4D, 0xE0, 0x81)
+	p.jr(len(*p))
+	buf := p.rom()
+	buf[0x143] = 0x80
+	emu := setupROM(buf, WithModel(CGB))
+	skipFrame(emu, 5)
+	assert.True(emu.bus.DoubleSpeed())
+	assert.Equal(byte(0xFE), emu.bus.ReadByte(0xFF81))
+	// A frame is 17556 M-cycles in normal speed, which is 68 TIMA ticks.
+	assert.InDelta(137, int(emu.bus.ReadByte(0xFF80)), 2)
+}
@@ -0,0 +1,161 @@
+package gb
+
+import (
+	"errors"
+
+	"github.com/bokuweb/gopher-boy/pkg/gpu"
+	"github.com/bokuweb/gopher-boy/pkg/types"
+)
+
+var (
+	// ErrInvalidLogo means the logo in cartridge header does not match
+	ErrInvalidLogo = errors.New("logo in cartridge header is invalid")
+	// ErrInvalidHeaderChecksum means header checksum at 0x014D does not match
+	ErrInvalidHeaderChecksum = errors.New("cartridge header checksum is invalid")
+)
+
+const (
+	logoAddr      types.Word = 0x0104
+	logoSize                 = 48
+	headerAddr    types.Word = 0x0134
+	logoTileAddr  types.Word = 0x8010
+	logoMapAddr   types.Word = 0x9904
+	logoTileCount            = 24
+	// Tile of the registered mark which follows logo tiles.
+	registeredTile byte = 0x19
+)
+
+// Boot sequence timing in frames.
+// The logo scrolls down one line per frame from SCY=0x64,
+// then the boot ROM plays the chime and waits with the logo shown.
+const (
+	hleScrollStart  = 0x64
+	hleWaitFrames   = 50
+	hleBootFrames   = hleScrollStart + hleWaitFrames
+	hleStepCycles   = 4
+	cyclesPerFrameM = CyclesPerFrame / 4
+)
+
+var nintendoLogo = [logoSize]byte{
+	0xCE, 0xED, 0x66, 0x66, 0xCC, 0x0D, 0x00, 0x0B, 0x03, 0x73, 0x00, 0x83, 0x00, 0x0C, 0x00, 0x0D,
+	0x00, 0x08, 0x11, 0x1F, 0x88, 0x89, 0x00, 0x0E, 0xDC, 0xCC, 0x6E, 0xE6, 0xDD, 0xDD, 0xD9, 0x99,
+	0xBB, 0xBB, 0x67, 0x63, 0x6E, 0x0E, 0xEC, 0xCC, 0xDD, 0xDC, 0x99, 0x9F, 0xBB, 0xB9, 0x33, 0x3E,
+}
+
+var registeredMark = [8]byte{0x3C, 0x42, 0xB9, 0xA5, 0xB9, 0xA5, 0x42, 0x3C}
+
+// hleBoot is state of high level emulated boot sequence.
+type hleBoot struct {
+	// elapsed M-cycles since power on
+	cycles uint
+	frame  uint
+	// locked is true when header is invalid and the boot never completes.
+	locked bool
+}
+
+// BootHLE runs boot sequence without boot ROM.
+// The cartridge logo is drawn into VRAM and scrolled, then the emulator starts
+// from 0x0100 with post-boot state of the model after the real boot duration.
+// The chime is not played since APU is not emulated.
+//
+// ErrInvalidLogo or ErrInvalidHeaderChecksum is returned when the header is invalid.
+// If strict is true, the boot locks up with the logo as the hardware does,
+// otherwise the error is only a warning and the cartridge is started.
+func (g *GB) BootHLE(strict bool) error {
+	err := g.checkHeader()
+	g.cpu.PowerOn()
+	g.gpu.PowerOn()
+	g.timer.SetCounter(0)
+	g.irq.IF = 0x00
+	for addr := 0x8000; addr < 0xA000; addr++ {
+		g.bus.Poke(types.Word(addr), 0x00)
+	}
+	g.drawLogo()
+	g.gpu.Write(gpu.SCROLLY, hleScrollStart)
+	g.gpu.Write(gpu.BGP, 0xFC)
+	g.gpu.Write(gpu.LCDC, 0x91)
+	g.boot = &hleBoot{locked: err != nil && strict}
+	return err
+}
+
+// Booting reports whether high level emulated boot sequence is running.
+func (g *GB) Booting() bool {
+	return g.boot != nil
+}
+
+// checkHeader compares the logo and header checksum as boot ROM does.
+// CGB boot ROM checks only the top half of the logo.
+func (g *GB) checkHeader() error {
+	size := logoSize
+	if g.model.cgb() {
+		size = logoSize / 2
+	}
+	for i := 0; i < size; i++ {
+		if g.bus.Peek(logoAddr+types.Word(i)) != nintendoLogo[i] {
+			return ErrInvalidLogo
+		}
+	}
+	var sum byte
+	for addr := headerAddr; addr < headerChecksumAddr; addr++ {
+		sum = sum - g.bus.Peek(addr) - 1
+	}
+	if sum != g.bus.Peek(headerChecksumAddr) {
+		return ErrInvalidHeaderChecksum
+	}
+	return nil
+}
+
+// drawLogo decompresses the logo in cartridge header into tiles.
+// Each nibble is a row of 4 pixels which is doubled in both directions.
+func (g *GB) drawLogo() {
+	addr := logoTileAddr
+	for i := 0; i < logoSize; i++ {
+		b := g.bus.Peek(logoAddr + types.Word(i))
+		for _, nibble := range []byte{b >> 4, b & 0x0F} {
+			row := doubleBits(nibble)
+			for n := 0; n < 2; n++ {
+				g.bus.Poke(addr, row)
+				addr += 2
+			}
+		}
+	}
+	for _, row := range registeredMark {
+		g.bus.Poke(addr, row)
+		addr += 2
+	}
+	// Top half is tile 1-12 and bottom half is tile 13-24.
+	for i := 0; i < logoTileCount/2; i++ {
+		g.bus.Poke(logoMapAddr+types.Word(i), byte(i+1))
+		g.bus.Poke(logoMapAddr+0x20+types.Word(i), byte(i+1+logoTileCount/2))
+	}
+	g.bus.Poke(logoMapAddr+logoTileCount/2, registeredTile)
+}
+
+func doubleBits(nibble byte) byte {
+	var b byte
+	for i := uint(0); i < 4; i++ {
+		if nibble&(0x08>>i) != 0 {
+			b |= 0xC0 >> (i * 2)
+		}
+	}
+	return b
+}
+
+// stepBoot advances boot sequence and returns elapsed M-cycles.
+func (g *GB) stepBoot() uint {
+	b := g.boot
+	b.cycles += hleStepCycles
+	frame := b.cycles / cyclesPerFrameM
+	if frame == b.frame {
+		return hleStepCycles
+	}
+	b.frame = frame
+	if frame <= hleScrollStart {
+		g.gpu.Write(gpu.SCROLLY, byte(hleScrollStart-frame))
+	}
+	if frame >= hleBootFrames && !b.locked {
+		g.boot = nil
+		g.skipBoot(true)
+	}
+	return hleStepCycles
+}
@@ -0,0 +1,229 @@
+package gb
+
+import (
+	"github.com/bokuweb/gopher-boy/pkg/bus"
+	"github.com/bokuweb/gopher-boy/pkg/cpu"
+	"github.com/bokuweb/gopher-boy/pkg/gpu"
+	"github.com/bokuweb/gopher-boy/pkg/types"
+)
+
+// Model is Game Boy hardware model
+type Model int
+
+const (
+	// DMG is DMG-01 with CPU revision A, B or C
+	DMG Model = iota
+	// DMG0 is early DMG-01 with CPU revision 0
+	DMG0
+	// MGB is Game Boy Pocket
+	MGB
+	// SGB is Super Game Boy
+	SGB
+	// SGB2 is Super Game Boy 2
+	SGB2
+	// CGB is Game Boy Color
+	CGB
+	// AGB is Game Boy Advance
+	AGB
+)
+
+var modelNames = map[Model]string{
+	DMG:  "DMG",
+	DMG0: "DMG0",
+	MGB:  "MGB",
+	SGB:  "SGB",
+	SGB2: "SGB2",
+	CGB:  "CGB",
+	AGB:  "AGB",
+}
+
+func (m Model) String() string {
+	return modelNames[m]
+}
+
+// cgb reports whether the model is CGB or its successor.
+func (m Model) cgb() bool {
+	return m == CGB || m == AGB
+}
+
+// ParseModel returns Model by name such as "DMG" or "CGB".
+func ParseModel(name string) (Model, bool) {
+	for m, n := range modelNames {
+		if n == name {
+			return m, true
+		}
+	}
+	return DMG, false
+}
+
+// ROMModel returns the model the ROM is made for.
+// It is CGB if CGB flag of the header is set, DMG otherwise.
+func ROMModel(rom []byte) Model {
+	if len(rom) > int(cgbFlagAddr) && rom[cgbFlagAddr]&0x80 != 0 {
+		return CGB
+	}
+	return DMG
+}
+
+// Option is GB constructor option
+type Option func(g *GB)
+
+// WithModel selects hardware model. Default is DMG.
+func WithModel(m Model) Option {
+	return func(g *GB) {
+		g.model = m
+	}
+}
+
+// Model returns selected hardware model.
+func (g *GB) Model() Model {
+	return g.model
+}
+
+// postBootState is hardware state when boot ROM jumps to 0x0100.
+// https://gbdev.io/pandocs/Power_Up_Sequence.html
+type postBootState struct {
+	regs cpu.Registers
+	// div is internal 16bit counter of the timer
+	div uint16
+	// ly and dot are position of PPU
+	ly  uint
+	dot uint
+	// p1 and sc are joypad select and serial control
+	p1 byte
+	sc byte
+	// channels are sound channels left on by the startup sound
+	channels byte
+}
+
+var postBootStates = map[Model]postBootState{
+	DMG0: {
+		regs:     cpu.Registers{A: 0x01, F: 0x00, B: 0xFF, C: 0x13, D: 0x00, E: 0xC1, H: 0x84, L: 0x03},
+		div:      0x1830,
+		ly:       0x91,
+		dot:      176,
+		p1:       0x00,
+		channels: 0x01,
+	},
+	DMG: {
+		regs:     cpu.Registers{A: 0x01, F: 0xB0, B: 0x00, C: 0x13, D: 0x00, E: 0xD8, H: 0x01, L: 0x4D},
+		div:      0xABCC,
+		ly:       153,
+		dot:      364,
+		p1:       0x00,
+		channels: 0x01,
+	},
+	MGB: {
+		regs:     cpu.Registers{A: 0xFF, F: 0xB0, B: 0x00, C: 0x13, D: 0x00, E: 0xD8, H: 0x01, L: 0x4D},
+		div:      0xABCC,
+		ly:       153,
+		dot:      364,
+		p1:       0x00,
+		channels: 0x01,
+	},
+	SGB: {
+		regs: cpu.Registers{A: 0x01, F: 0x00, B: 0x00, C: 0x14, D: 0x00, E: 0x00, H: 0xC0, L: 0x60},
+		div:  0xD860,
+		ly:   0,
+		dot:  0,
+		p1:   0x30,
+	},
+	SGB2: {
+		regs: cpu.Registers{A: 0xFF, F: 0x00, B: 0x00, C: 0x14, D: 0x00, E: 0x00, H: 0xC0, L: 0x60},
+		div:  0xD860,
+		ly:   0,
+		dot:  0,
+		p1:   0x30,
+	},
+	CGB: {
+		regs:     cpu.Registers{A: 0x11, F: 0x80, B: 0x00, C: 0x00, D: 0xFF, E: 0x56, H: 0x00, L: 0x0D},
+		div:      0x1EA0,
+		ly:       0x90,
+		dot:      0,
+		p1:       0x00,
+		sc:       0x03,
+		channels: 0x01,
+	},
+	AGB: {
+		regs:     cpu.Registers{A: 0x11, F: 0x00, B: 0x01, C: 0x00, D: 0xFF, E: 0x56, H: 0x00, L: 0x0D},
+		div:      0x1EA0,
+		ly:       0x90,
+		dot:      0,
+		p1:       0x00,
+		sc:       0x03,
+		channels: 0x01,
+	},
+}
+
+// postBootSound is sound registers boot ROM leaves, sound is turned on first.
+var postBootSound = []struct {
+	addr types.Word
+	data byte
+}{
+	{bus.NR52Reg, 0x80},
+	{0xFF11, 0x80},
+	{0xFF12, 0xF3},
+	{0xFF24, 0x77},
+	{0xFF25, 0xF3},
+}
+
+const (
+	cgbFlagAddr        types.Word = 0x0143
+	headerChecksumAddr types.Word = 0x014D
+)
+
+// skipBoot sets registers and I/O as if boot ROM of the model was executed.
+// manual lets keys held at the end of boot select palettes of colourised games.
+func (g *GB) skipBoot(manual bool) {
+	s := postBootStates[g.model]
+	regs := s.regs
+	cgb := false
+	switch g.model {
+	case DMG, MGB:
+		// Boot ROM leaves flags of header checksum calculation.
+		if g.bus.Peek(headerChecksumAddr) == 0x00 {
+			regs.F = 0x80
+		}
+	case CGB, AGB:
+		// DMG cartridge in CGB compatibility mode
+		cgb = g.bus.Peek(cgbFlagAddr)&0x80 != 0
+		if !cgb {
+			regs.D, regs.E, regs.L = 0x00, 0x08, 0x7C
+		}
+	}
+	g.bus.SetCGBMode(cgb)
+	g.cpu.Regs = regs
+	g.cpu.SP = 0xFFFE
+	g.cpu.PC = 0x0100
+	g.timer.SetCounter(s.div)
+	g.irq.IF = 0x01
+	g.bus.Poke(0xFF00, s.p1)
+	g.bus.Poke(bus.SCReg, s.sc)
+	for _, r := range postBootSound {
+		g.bus.Poke(r.addr, r.data)
+	}
+	g.bus.SetSoundChannels(s.channels)
+	g.gpu.Write(gpu.LCDC, 0x91)
+	g.gpu.Write(gpu.BGP, 0xFC)
+	g.gpu.Write(gpu.OBP0, 0xFF)
+	g.gpu.Write(gpu.OBP1, 0xFF)
+	g.gpu.SetLine(s.ly, s.dot)
+	if cgb {
+		g.initColorPalettes()
+	}
+	if g.colorize != nil {
+		g.colorizePalettes(manual)
+	}
+	if g.sgb != nil {
+		g.sgb.SetEnabled(g.sgbCartridge())
+	}
+}
+
+// initColorPalettes fills BG palettes with white as CGB boot ROM does.
+// OBJ palettes are left uninitialized.
+func (g *GB) initColorPalettes() {
+	g.gpu.Write(gpu.BCPS, 0x80)
+	for i := 0; i < 64; i++ {
+		g.gpu.Write(gpu.BCPD, 0xFF)
+	}
+}
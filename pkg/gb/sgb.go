@@ -0,0 +1,52 @@
+package gb
+
+import (
+	"github.com/bokuweb/gopher-boy/pkg/gpu"
+	"github.com/bokuweb/gopher-boy/pkg/sgb"
+	"github.com/bokuweb/gopher-boy/pkg/types"
+)
+
+const (
+	sgbFlagAddr types.Word = 0x0146
+	// sgbSupported in SGB flag means the cartridge uses SGB functions.
+	sgbSupported byte = 0x03
+)
+
+// WithSGB runs the emulator as Super Game Boy, s should be the pad of bus.
+// Next returns sgb.Width x sgb.Height image with the border.
+func WithSGB(s *sgb.SGB) Option {
+	return func(g *GB) {
+		g.sgb = s
+	}
+}
+
+// sgbCartridge reports whether SGB BIOS accepts packets from the cartridge,
+// which requires SGB flag and the old licensee code 0x33.
+func (g *GB) sgbCartridge() bool {
+	return g.bus.Peek(sgbFlagAddr) == sgbSupported && g.bus.Peek(oldLicenseeAddr) == useNewLicensee
+}
+
+// endSGBBoot enables packets from the cartridge when SGB boot ROM is unmapped,
+// as SGB BIOS receives the header from boot ROM before it.
+func (g *GB) endSGBBoot() {
+	if g.bus.BootROMMapped() {
+		return
+	}
+	g.sgbBoot = false
+	g.sgb.SetEnabled(g.sgbCartridge())
+}
+
+// image returns the screen, which is SGB output in SGB mode.
+func (g *GB) image() []byte {
+	if g.sgb != nil {
+		return g.sgb.Render(g.gpu.IndexedImage())
+	}
+	return g.gpu.GetImageData()
+}
+
+// endFrame runs SGB VRAM transfer from the screen of the completed frame.
+func (g *GB) endFrame() {
+	if g.sgb != nil {
+		g.sgb.Transfer(g.bus, g.gpu.Read(gpu.LCDC))
+	}
+}
@@ -0,0 +1,149 @@
+package gpu
+
+import (
+	"image/color"
+
+	"github.com/bokuweb/gopher-boy/pkg/types"
+)
+
+// CGB register addresses
+const (
+	// BCPS is BG palette index, bit 7 enables auto-increment on BCPD write.
+	BCPS types.Word = 0x28
+	// BCPD is BG palette data at BCPS index.
+	BCPD = 0x29
+	// OCPS is OBJ palette index, bit 7 enables auto-increment on OCPD write.
+	OCPS = 0x2A
+	// OCPD is OBJ palette data at OCPS index.
+	OCPD = 0x2B
+	// OPRI selects object priority, 0 is by OAM index and 1 is by X as DMG.
+	OPRI = 0x2C
+)
+
+// BG map attributes in VRAM bank 1, sprite attributes use palette and bank bits too.
+const (
+	attrPalette  byte = 0x07
+	attrBank     byte = 0x08
+	attrXFlip    byte = 0x20
+	attrYFlip    byte = 0x40
+	attrPriority byte = 0x80
+)
+
+const colorPaletteSize = 64
+
+// colorPalette is CGB palette RAM, 8 palettes of 4 colours in little endian RGB555.
+type colorPalette struct {
+	data [colorPaletteSize]byte
+	// index is BCPS or OCPS
+	index byte
+}
+
+func (p *colorPalette) readIndex() byte {
+	// bit 6 is unused
+	return p.index | 0x40
+}
+
+func (p *colorPalette) writeIndex(data byte) {
+	p.index = data & 0xBF
+}
+
+func (p *colorPalette) read() byte {
+	return p.data[p.index&0x3F]
+}
+
+// write stores data at the index unless the palette RAM is locked,
+// the index is incremented even if the write is dropped.
+func (p *colorPalette) write(data byte, locked bool) {
+	if !locked {
+		p.data[p.index&0x3F] = data
+	}
+	if p.index&0x80 != 0 {
+		p.index = 0x80 | (p.index+1)&0x3F
+	}
+}
+
+func (p *colorPalette) color(palette, c byte) color.RGBA {
+	i := palette*8 + c*2
+	v := uint16(p.data[i]) | uint16(p.data[i+1])<<8
+	return color.RGBA{R: scale5(v), G: scale5(v >> 5), B: scale5(v >> 10), A: 0xFF}
+}
+
+// scale5 converts 5bit colour component to 8bit.
+func scale5(v uint16) byte {
+	v &= 0x1F
+	return byte(v<<3 | v>>2)
+}
+
+// SetCGBMode switches CGB mode, which enables colour palettes, VRAM bank 1,
+// BG map attributes and CGB sprite priority.
+// DMG cartridges on CGB run in DMG compatible mode with CGB mode off.
+func (g *GPU) SetCGBMode(on bool) {
+	g.cgb = on
+}
+
+// SetCGBHardware tells GPU that it runs on CGB. It differs from CGB mode in
+// that DMG cartridges run on CGB without CGB mode.
+func (g *GPU) SetCGBHardware(on bool) {
+	g.cgbHardware = on
+}
+
+// CGBMode reports whether GPU runs in CGB mode.
+func (g *GPU) CGBMode() bool {
+	return g.cgb
+}
+
+// paletteLocked reports whether CPU can not access CGB palette RAM.
+// Palette RAM is locked while PPU outputs pixels in mode 3.
+func (g *GPU) paletteLocked() bool {
+	return g.lcdEnabled() && g.mode == TransferingData
+}
+
+// readCGB reads CGB registers, which read 0xFF in DMG mode.
+func (g *GPU) readCGB(addr types.Word) byte {
+	if !g.cgb {
+		return 0xFF
+	}
+	switch addr {
+	case BCPS:
+		return g.bgColors.readIndex()
+	case BCPD:
+		if g.paletteLocked() {
+			return 0xFF
+		}
+		return g.bgColors.read()
+	case OCPS:
+		return g.objColors.readIndex()
+	case OCPD:
+		if g.paletteLocked() {
+			return 0xFF
+		}
+		return g.objColors.read()
+	case OPRI:
+		return g.opri | 0xFE
+	}
+	return 0xFF
+}
+
+func (g *GPU) writeCGB(addr types.Word, data byte) {
+	if !g.cgb {
+		return
+	}
+	switch addr {
+	case BCPS:
+		g.bgColors.writeIndex(data)
+	case BCPD:
+		g.bgColors.write(data, g.paletteLocked())
+	case OCPS:
+		g.objColors.writeIndex(data)
+	case OCPD:
+		g.objColors.write(data, g.paletteLocked())
+	case OPRI:
+		g.opri = data & 0x01
+	}
+}
+
+// objPriorityByIndex reports whether overlapping sprites are prioritized
+// by OAM index as CGB instead of X.
+func (g *GPU) objPriorityByIndex() bool {
+	return g.cgb && g.opri&0x01 == 0
+}
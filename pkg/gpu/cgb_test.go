@@ -0,0 +1,133 @@
+package gpu
+
+import (
+	"testing"
+
+	"github.com/bokuweb/gopher-boy/pkg/interrupt"
+	"github.com/bokuweb/gopher-boy/pkg/mocks"
+	"github.com/stretchr/testify/assert"
+)
+
+// setupCGB returns GPU in CGB mode with sprites in OAM, see setupSprites.
+// Colour c of BG palette p is red p*4+c and colour c of OBJ palette p is green p*4+c.
+func setupCGB(lcdc byte, oam ...byte) (*GPU, *mocks.MockBus) {
+	g, b := setupSprites(lcdc, oam...)
+	g.SetCGBMode(true)
+	g.Write(BCPS, 0x80)
+	g.Write(OCPS, 0x80)
+	for i := byte(0); i < 32; i++ {
+		g.Write(BCPD, i)
+		g.Write(BCPD, 0x00)
+		g.Write(OCPD, i<<5)
+		g.Write(OCPD, i>>3)
+	}
+	return g, b
+}
+
+// rgb5 returns 5bit red and green of the pixel on line 0.
+func rgb5(g *GPU, x int) (byte, byte) {
+	d := g.GetImageData()[x*4:]
+	return d[0] >> 3, d[1] >> 3
+}
+
+func TestColorPalette(t *testing.T) {
+	assert := assert.New(t)
+	g := setup()
+	assert.Equal(byte(0xFF), g.Read(BCPS))
+	g.Write(BCPS, 0x80)
+	assert.Equal(byte(0xFF), g.Read(BCPS))
+
+	g.SetCGBMode(true)
+	g.Write(BCPS, 0xBE)
+	g.Write(BCPD, 0x12)
+	g.Write(BCPD, 0x34)
+	// The index wraps around.
+	assert.Equal(byte(0xC0), g.Read(BCPS))
+	g.Write(BCPS, 0x3F)
+	assert.Equal(byte(0x34), g.Read(BCPD))
+	// Reading does not increment the index.
+	assert.Equal(byte(0x7F), g.Read(BCPS))
+
+	// Palette RAM is locked in mode 3 but the index is still incremented.
+	g.Write(OCPS, 0x80)
+	g.Step(transferStart + 1)
+	assert.Equal(TransferingData, g.Read(STAT)&0x03)
+	g.Write(OCPD, 0x56)
+	assert.Equal(byte(0xFF), g.Read(OCPD))
+	assert.Equal(byte(0xC1), g.Read(OCPS))
+	g.Step(300)
+	g.Write(OCPS, 0x00)
+	assert.Equal(byte(0x00), g.Read(OCPD))
+}
+
+func TestCGBBGAttributes(t *testing.T) {
+	assert := assert.New(t)
+	g, b := setupCGB(0x91)
+	// The last row of tile 0 in bank 1 has only the first pixel.
+	b.MockVRAM1[0x0E] = 0x80
+	b.MockVRAM1[0x0F] = 0x80
+	b.MockVRAM1[TILEMAP0-0x8000] = attrBank | attrXFlip | attrYFlip | 0x03
+	g.Step(CyclePerLine)
+	r, _ := rgb5(g, 0)
+	assert.Equal(byte(12), r)
+	r, _ = rgb5(g, 7)
+	assert.Equal(byte(15), r)
+	r, _ = rgb5(g, 8)
+	assert.Equal(byte(0), r)
+}
+
+func TestCGBSpritePriority(t *testing.T) {
+	assert := assert.New(t)
+	// Sprite 0 with colour 2 is drawn over sprite 1 with colour 3 and smaller X.
+	g, _ := setupCGB(0x93, 16, 12, 2, 0, 16, 8, 1, 0)
+	g.Step(CyclePerLine)
+	_, c := rgb5(g, 4)
+	assert.Equal(byte(2), c)
+
+	g, _ = setupCGB(0x93, 16, 12, 2, 0, 16, 8, 1, 0)
+	g.Write(OPRI, 0x01)
+	g.Step(CyclePerLine)
+	_, c = rgb5(g, 4)
+	assert.Equal(byte(3), c)
+}
+
+func TestCGBBGPriority(t *testing.T) {
+	assert := assert.New(t)
+	g, b := setupCGB(0x93, 16, 8, 1, 0x01)
+	// Left half of BG tile 0 is colour 1 with BG priority.
+	b.SetMemory(TILEDATA1, []byte{0xF0, 0x00})
+	b.MockVRAM1[TILEMAP0-0x8000] = attrPriority
+	g.Step(CyclePerLine)
+	r, c := rgb5(g, 3)
+	assert.Equal(byte(1), r)
+	assert.Equal(byte(0), c)
+	_, c = rgb5(g, 4)
+	assert.Equal(byte(7), c)
+
+	// LCDC bit 0 clears BG priority instead of BG in CGB mode.
+	g, b = setupCGB(0x92, 16, 8, 1, 0x01)
+	b.SetMemory(TILEDATA1, []byte{0xF0, 0x00})
+	b.MockVRAM1[TILEMAP0-0x8000] = attrPriority
+	g.Step(CyclePerLine)
+	_, c = rgb5(g, 3)
+	assert.Equal(byte(7), c)
+	// BG is still drawn.
+	r, _ = rgb5(g, 8)
+	assert.Equal(byte(1), r)
+}
+
+func TestCGBSTATWrite(t *testing.T) {
+	assert := assert.New(t)
+	g := NewGPU()
+	irq := interrupt.NewInterrupt()
+	g.Init(&mocks.MockBus{}, irq)
+	// CGB does not have the quirk even for DMG cartridges.
+	g.SetCGBHardware(true)
+	g.Step(260)
+	statIRQ(irq)
+	g.Write(STAT, 0x00)
+	assert.False(statIRQ(irq))
+	g.SetCGBHardware(false)
+	g.Write(STAT, 0x00)
+	assert.True(statIRQ(irq))
+}
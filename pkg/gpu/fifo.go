@@ -0,0 +1,243 @@
+package gpu
+
+import "github.com/bokuweb/gopher-boy/pkg/types"
+
+const fifoSize = 16
+
+// bgPixel is a BG or window pixel in the FIFO.
+type bgPixel struct {
+	color byte
+	// palette and priority are from BG map attributes in CGB mode
+	palette  byte
+	priority bool
+	// window is true for window pixels
+	window bool
+}
+
+// pixelFIFO is a queue of BG and window pixels.
+type pixelFIFO struct {
+	buf  [fifoSize]bgPixel
+	head int
+	size int
+}
+
+func (f *pixelFIFO) push(p bgPixel) {
+	f.buf[(f.head+f.size)%fifoSize] = p
+	f.size++
+}
+
+func (f *pixelFIFO) pop() bgPixel {
+	c := f.buf[f.head]
+	f.head = (f.head + 1) % fifoSize
+	f.size--
+	return c
+}
+
+func (f *pixelFIFO) clear() {
+	f.head = 0
+	f.size = 0
+}
+
+type fetcherState byte
+
+const (
+	fetchTileID fetcherState = iota
+	fetchTileLow
+	fetchTileHigh
+	fetchPush
+)
+
+// fetcher reads a row of BG or window tile into the FIFO.
+// Each step except push takes 2 dots and push waits until the FIFO is empty.
+type fetcher struct {
+	state  fetcherState
+	dot    byte
+	x      uint
+	window bool
+	tileID byte
+	// attr is BG map attribute in CGB mode
+	attr byte
+	low  byte
+	high byte
+}
+
+func (f *fetcher) reset(window bool) {
+	*f = fetcher{window: window}
+}
+
+func (g *GPU) tickFetcher() {
+	f := &g.fetcher
+	if f.state != fetchPush {
+		f.dot++
+		if f.dot < 2 {
+			return
+		}
+		f.dot = 0
+	}
+	switch f.state {
+	case fetchTileID:
+		if f.window && !g.windowEnabled() {
+			g.leaveWindow()
+		}
+		addr := g.fetchTileMapAddr()
+		f.tileID = g.bus.ReadVRAM(addr)
+		if g.cgb {
+			f.attr = g.bus.ReadVRAMBank(addr, 1)
+		}
+		f.state = fetchTileLow
+	case fetchTileLow:
+		f.low = g.bus.ReadVRAMBank(g.fetchTileRowAddr(), f.attr&attrBank>>3)
+		f.state = fetchTileHigh
+	case fetchTileHigh:
+		f.high = g.bus.ReadVRAMBank(g.fetchTileRowAddr()+1, f.attr&attrBank>>3)
+		f.state = fetchPush
+	case fetchPush:
+		if g.bgFIFO.size != 0 {
+			return
+		}
+		for i := uint(0); i < 8; i++ {
+			bit := 7 - i
+			if f.attr&attrXFlip != 0 {
+				bit = i
+			}
+			g.bgFIFO.push(bgPixel{
+				color:    (f.high>>bit)&0x01<<1 | (f.low>>bit)&0x01,
+				palette:  f.attr & attrPalette,
+				priority: f.attr&attrPriority != 0,
+				window:   f.window,
+			})
+		}
+		f.x++
+		f.state = fetchTileID
+	}
+}
+
+// fetchTileMapAddr returns tile map address of the tile to fetch.
+// SCX and SCY are read on each fetch.
+func (g *GPU) fetchTileMapAddr() types.Word {
+	f := &g.fetcher
+	if f.window {
+		return g.getWindowTilemapAddr() + types.Word(g.windowLine/8*32+f.x%32)
+	}
+	y := (g.ly + uint(g.scrollY)) & 0xFF
+	x := (uint(g.scrollX)/8 + f.x) % 32
+	return g.getBGTilemapAddr() + types.Word(y/8*32+x)
+}
+
+// fetchTileRowAddr returns address of the low byte of the tile row to fetch.
+func (g *GPU) fetchTileRowAddr() types.Word {
+	f := &g.fetcher
+	y := (g.ly + uint(g.scrollY)) % 8
+	if f.window {
+		y = g.windowLine % 8
+	}
+	if f.attr&attrYFlip != 0 {
+		y = 7 - y
+	}
+	return g.tileDataAddr(f.tileID) + types.Word(y*2)
+}
+
+// objPixel is a sprite pixel in object FIFO.
+type objPixel struct {
+	color byte
+	// obp1 selects OBP1, palette is applied when the pixel is output
+	obp1 bool
+	// palette is CGB OBJ palette
+	palette  byte
+	behindBG bool
+	// x is OAM X of the sprite, used for DMG sprite priority
+	x byte
+	// index is OAM index of the sprite, used for CGB sprite priority
+	index int
+}
+
+// objectFIFO holds sprite pixels aligned with the BG pixels to output.
+type objectFIFO struct {
+	buf  [8]objPixel
+	head int
+	size int
+}
+
+func (f *objectFIFO) at(i int) *objPixel {
+	return &f.buf[(f.head+i)%len(f.buf)]
+}
+
+func (f *objectFIFO) pop() objPixel {
+	p := f.buf[f.head]
+	f.head = (f.head + 1) % len(f.buf)
+	f.size--
+	return p
+}
+
+func (f *objectFIFO) clear() {
+	f.head = 0
+	f.size = 0
+}
+
+// merge puts sprite pixels into the FIFO.
+// On DMG, the sprite with smaller X wins and then the one earlier in OAM,
+// which is the one fetched first. If byIndex is true, the one earlier in OAM wins as CGB.
+func (f *objectFIFO) merge(pixels []objPixel, byIndex bool) {
+	for i, p := range pixels {
+		if i >= f.size {
+			*f.at(i) = p
+			f.size++
+			continue
+		}
+		cur := f.at(i)
+		wins := p.x < cur.x
+		if byIndex {
+			wins = p.index < cur.index
+		}
+		if cur.color == 0 || (p.color != 0 && wins) {
+			*cur = p
+		}
+	}
+}
+
+// loadSprite fetches a row of the sprite and merges it into object FIFO.
+func (g *GPU) loadSprite(s lineSprite) {
+	base := OAMSTART + types.Word(s.index*4)
+	y := int(g.ly) - (int(g.bus.ReadOAM(base)) - 16)
+	tileID := g.bus.ReadOAM(base + 2)
+	attr := g.bus.ReadOAM(base + 3)
+	height := 8
+	if g.longSprite() {
+		height = 16
+		// LSB is ignored (treated as 0) in 8x16 mode.
+		tileID &= 0xFE
+	}
+	if attr&0x40 != 0 {
+		y = height - 1 - y
+	}
+	addr := TILEDATA1 + types.Word(tileID)*0x10 + types.Word(y*2)
+	// VRAM bank and palette attributes are only used in CGB mode.
+	var bank, palette byte
+	if g.cgb {
+		bank = attr & attrBank >> 3
+		palette = attr & attrPalette
+	}
+	low := g.bus.ReadVRAMBank(addr, bank)
+	high := g.bus.ReadVRAMBank(addr+1, bank)
+	var pixels [8]objPixel
+	for i := uint(0); i < 8; i++ {
+		bit := 7 - i
+		if attr&0x20 != 0 {
+			bit = i
+		}
+		pixels[i] = objPixel{
+			color:    (high>>bit)&0x01<<1 | (low>>bit)&0x01,
+			obp1:     attr&0x10 != 0,
+			palette:  palette,
+			behindBG: attr&0x80 != 0,
+			x:        s.x,
+			index:    s.index,
+		}
+	}
+	// Pixels left of the screen are not shifted out.
+	skip := 0
+	if s.x < 8 {
+		skip = 8 - int(s.x)
+	}
+	g.objFIFO.merge(pixels[skip:], g.objPriorityByIndex())
+}
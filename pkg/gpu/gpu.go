@@ -18,27 +18,100 @@ const LCDVBlankHeight uint = 10
 
 const spriteNum = 40
 
+// lineSpriteMax is max number of sprites on a line.
+const lineSpriteMax = 10
+
+const (
+	// oamScanStart is the dot mode 2 starts at. LY is incremented at the start of
+	// the line, but STAT reads mode 0 for the first 4 dots.
+	oamScanStart uint = 4
+	// oamScanDots is length of mode 2
+	oamScanDots uint = 80
+	// transferStart is the dot mode 3 starts at.
+	transferStart = oamScanStart + oamScanDots
+	// First tile of each line is fetched twice, which stalls the FIFO for 3 dots
+	// and makes mode 3 169 dots long without SCX fine scroll, window or sprites.
+	transferStartDelay uint = 3
+	// The first line after LCD is turned on is 4 dots shorter.
+	lcdOnLineOffset uint = 4
+	// hblankSTATDelay is dots from mode 0 to HBlank STAT interrupt.
+	hblankSTATDelay uint = 4
+)
+
+// lineSprite is a sprite found in OAM scan.
+type lineSprite struct {
+	index int
+	x     byte
+}
+
 // GPU is
 type GPU struct {
-	bus             bus.Accessor
-	irq             interrupt.Interrupt
-	imageData       []byte
-	mode            GPUMode
-	clock           uint
-	lcdc            byte
-	stat            byte
-	ly              uint
-	lyc             byte
-	scrollX         byte
-	scrollY         byte
-	windowX         byte
-	windowY         byte
-	bgPalette       byte
-	objPalette0     byte
-	objPalette1     byte
-	disableDisplay  bool
-	oamDMAStarted   bool
-	oamDMAStartAddr types.Word
+	bus         bus.VideoAccessor
+	irq         interrupt.Interrupt
+	imageData   []byte
+	indexed     []IndexedPixel
+	mode        GPUMode
+	clock       uint
+	lcdc        byte
+	stat        byte
+	ly          uint
+	lyc         byte
+	scrollX     byte
+	scrollY     byte
+	windowX     byte
+	windowY     byte
+	bgPalette   byte
+	objPalette0 byte
+	objPalette1 byte
+	// dma is the last value written to DMA, the transfer is run by bus.
+	dma byte
+	// palettes are colours of the shades BGP, OBP0 and OBP1 select
+	palettes Palettes
+
+	// cgb enables CGB mode, see SetCGBMode
+	cgb bool
+	// cgbHardware is set on CGB even in DMG compatible mode, see SetCGBHardware
+	cgbHardware bool
+	bgColors    colorPalette
+	objColors   colorPalette
+	opri        byte
+
+	// hiddenLayers are layers hidden for debugging, see SetLayerVisible
+	hiddenLayers Layer
+	// layers records images of layers when enabled, see EnableLayerBuffers
+	layers *layerBuffers
+	// lineHook is called at HBlank of each line, see SetLineHook
+	lineHook LineHook
+
+	// Pixel FIFO states in mode 3
+	bgFIFO  pixelFIFO
+	fetcher fetcher
+	// lx is x of next pixel to output
+	lx uint
+	// discard is number of pixels dropped by fine scroll
+	discard byte
+	// stall is number of dots that FIFO is paused
+	stall         uint
+	objFIFO       objectFIFO
+	lineSprites   [lineSpriteMax]lineSprite
+	lineSpriteNum int
+	spriteFetched uint
+	// spriteTile is BG tile of the last fetched sprite
+	spriteTile int
+
+	// statLine is internal STAT interrupt line
+	statLine bool
+	// hblankSource is the dot HBlank source of STAT interrupt becomes active on the line
+	hblankSource uint
+	// windowLine is internal window line counter
+	windowLine     uint
+	windowDrawn    bool
+	wyTriggered    bool
+	windowNextLine bool
+	// lcdOnLine is true on the first line after LCD is turned on
+	lcdOnLine bool
+	// blank is true while the screen is not displayed
+	blank bool
 }
 
 // GPUMode
@@ -53,6 +126,14 @@ const (
 	TransferingData
 )
 
+// STAT interrupt sources
+const (
+	statHBlank byte = 0x08
+	statVBlank byte = 0x10
+	statOAM    byte = 0x20
+	statLYC    byte = 0x40
+)
+
 // GPU register addresses
 const (
 	LCDC types.Word = 0x00
@@ -104,84 +185,475 @@ const (
 // NewGPU is GPU constructor
 func NewGPU() *GPU {
 	return &GPU{
-		imageData:       make([]byte, constants.ScreenWidth*constants.ScreenHeight*4),
-		mode:            HBlankMode,
-		clock:           0,
-		lcdc:            0x91,
-		ly:              0,
-		scrollX:         0,
-		scrollY:         0,
-		oamDMAStarted:   false,
-		oamDMAStartAddr: 0,
+		imageData: make([]byte, constants.ScreenWidth*constants.ScreenHeight*4),
+		indexed:   newIndexedImage(),
+		mode:      SearchingOAMMode,
+		clock:     0,
+		lcdc:      0x91,
+		ly:        0,
+		scrollX:   0,
+		scrollY:   0,
+		palettes:  DefaultPalettes,
+	}
+}
+
+// turnOff stops PPU at the top of the frame in mode 0 and blanks the screen.
+func (g *GPU) turnOff() {
+	g.ly = 0
+	g.clock = 0
+	g.mode = HBlankMode
+	g.windowLine = 0
+	g.windowDrawn = false
+	g.wyTriggered = false
+	g.windowNextLine = false
+	// LY=LYC flag keeps the last value while LCD is off.
+	g.updateSTATLine()
+	g.blank = true
+	rgba := g.palettes.BG[0]
+	if g.cgb {
+		rgba = color.RGBA{R: 0xFF, G: 0xFF, B: 0xFF, A: 0xFF}
+	}
+	for i := 0; i < len(g.imageData); i += 4 {
+		g.imageData[i] = rgba.R
+		g.imageData[i+1] = rgba.G
+		g.imageData[i+2] = rgba.B
+		g.imageData[i+3] = rgba.A
+	}
+	for i := range g.indexed {
+		g.indexed[i] = IndexedPixel{}
+	}
+	if g.layers != nil {
+		g.layers.clear()
+	}
+}
+
+// turnOn restarts PPU from line 0.
+// The first line skips OAM scan and stays in mode 0 until mode 3,
+// and it is 4 dots shorter than other lines.
+// The screen stays blank until the first frame is completed.
+func (g *GPU) turnOn() {
+	g.ly = 0
+	g.clock = lcdOnLineOffset
+	g.mode = HBlankMode
+	g.hblankSource = 0
+	g.lcdOnLine = true
+	g.compareLY()
+	g.updateSTATLine()
+}
+
+// ScreenBlank reports whether the LCD shows a blank screen,
+// which is while LCD is off and the first frame after it is turned on.
+func (g *GPU) ScreenBlank() bool {
+	return g.blank
+}
+
+// VRAMAccessible reports whether CPU can access VRAM.
+// VRAM is locked while PPU fetches tiles in mode 3,
+// and reads are also locked in the last 4 dots of OAM scan.
+func (g *GPU) VRAMAccessible(write bool) bool {
+	if !g.lcdEnabled() {
+		return true
+	}
+	if g.mode == SearchingOAMMode && !write {
+		return g.clock < transferStart-4
+	}
+	return g.mode != TransferingData
+}
+
+// OAMAccessible reports whether CPU can access OAM.
+// OAM is locked in OAM scan and mode 3. Reads are also locked from the start
+// of the line although STAT reads mode 0 until OAM scan starts,
+// and writes are accepted in the last 4 dots of OAM scan.
+func (g *GPU) OAMAccessible(write bool) bool {
+	if !g.lcdEnabled() {
+		return true
+	}
+	switch g.mode {
+	case HBlankMode:
+		if !write && g.clock < oamScanStart && !g.lcdOnLine {
+			return g.ly >= constants.ScreenHeight
+		}
+		return true
+	case VBlankMode:
+		return true
+	case SearchingOAMMode:
+		return write && g.clock >= transferStart-4
+	}
+	return false
+}
+
+// Mode returns current PPU mode.
+func (g *GPU) Mode() GPUMode {
+	return g.mode
+}
+
+// PowerOn resets GPU to power-on state, LCD is turned off until boot ROM enables it.
+func (g *GPU) PowerOn() {
+	g.mode = HBlankMode
+	g.clock = 0
+	g.lcdc = 0x00
+	g.stat = 0x00
+	g.ly = 0
+	g.lyc = 0
+	g.scrollX, g.scrollY = 0, 0
+	g.windowX, g.windowY = 0, 0
+	g.bgPalette, g.objPalette0, g.objPalette1 = 0, 0, 0
+	g.dma = 0
+	g.bgFIFO.clear()
+	g.turnOff()
+}
+
+// SetLine sets LY and the clock in the line, used to set post-boot state.
+func (g *GPU) SetLine(ly uint, clock uint) {
+	g.ly = ly
+	g.clock = clock
+	switch {
+	case ly >= constants.ScreenHeight:
+		g.mode = VBlankMode
+	case clock >= oamScanStart && clock < transferStart:
+		g.mode = SearchingOAMMode
+	default:
+		g.mode = HBlankMode
+	}
+	if g.ly == uint(g.lyc) {
+		g.stat |= 0x04
+	} else {
+		g.stat &= 0xFB
 	}
 }
 
 // Init initialize GPU
-func (g *GPU) Init(bus bus.Accessor, irq interrupt.Interrupt) {
+func (g *GPU) Init(bus bus.VideoAccessor, irq interrupt.Interrupt) {
 	g.bus = bus
 	g.irq = irq
 }
 
 // Step is run GPU
+// PPU runs dot by dot, so mid-scanline register writes take effect at the next pixel.
 func (g *GPU) Step(cycles uint) {
 	if g.bus == nil {
 		panic("Please initialize gpu with Init, before running.")
 	}
-	g.updateMode()
+	if !g.lcdEnabled() {
+		return
+	}
+	for i := uint(0); i < cycles; i++ {
+		g.tick()
+	}
+}
+
+func (g *GPU) tick() {
+	switch g.mode {
+	case SearchingOAMMode:
+		if g.clock == transferStart-1 {
+			g.startTransfer()
+		}
+	case HBlankMode:
+		if g.lcdOnLine && g.clock == transferStart-1 {
+			g.startTransfer()
+		}
+	case TransferingData:
+		g.transfer()
+	}
+	g.clock++
+	switch g.clock {
+	case CyclePerLine:
+		g.clock = 0
+		g.nextLine()
+	case oamScanStart:
+		g.startLine()
+	}
+	g.updateSTATLine()
+}
 
-	g.clock += cycles
+func (g *GPU) nextLine() {
+	// Window line counter is incremented only on lines the window is drawn.
+	if g.windowDrawn {
+		g.windowDrawn = false
+		g.windowLine++
+	}
+	g.ly++
+	if g.ly == constants.ScreenHeight+LCDVBlankHeight {
+		g.ly = 0
+	}
+	// LY=LYC flag is cleared until the line starts.
+	g.stat &= 0xFB
+	g.hblankSource = 0
+}
 
+// startLine enters mode 2 or VBlank 4 dots after LY is incremented.
+func (g *GPU) startLine() {
+	switch {
+	case g.ly == constants.ScreenHeight:
+		g.mode = VBlankMode
+		g.blank = false
+		g.wyTriggered = false
+		g.windowNextLine = false
+		g.windowLine = 0
+		g.irq.SetIRQ(irq.VerticalBlankFlag)
+	case g.ly < constants.ScreenHeight:
+		g.mode = SearchingOAMMode
+	}
+	g.compareLY()
+}
+
+func (g *GPU) compareLY() {
+	if g.ly == uint(g.lyc) {
+		g.stat |= 0x04
+	} else {
+		g.stat &= 0xFB
+	}
+}
+
+// statSignal reports whether any of STAT interrupt sources selected by
+// enabled is active.
+func (g *GPU) statSignal(enabled byte) bool {
+	// LY=LYC flag keeps the value while LCD is off, and so does the LYC source.
 	if !g.lcdEnabled() {
+		return enabled&statLYC != 0 && g.stat&0x04 != 0
+	}
+	switch {
+	case enabled&statLYC != 0 && g.stat&0x04 != 0:
+		return true
+	case enabled&statHBlank != 0 && g.mode == HBlankMode && g.clock >= g.hblankSource:
+		return true
+	case enabled&statVBlank != 0 && g.mode == VBlankMode:
+		return true
+	// Mode 2 source is also active at the start of line 144.
+	case enabled&statOAM != 0 && (g.mode == SearchingOAMMode || (g.ly == constants.ScreenHeight && g.clock == oamScanStart)):
+		return true
+	}
+	return false
+}
+
+// updateSTATLine updates internal STAT interrupt line which ORs all enabled sources.
+// The interrupt is requested only on rising edge of the line,
+// so a source becoming active while another one is active is blocked.
+func (g *GPU) updateSTATLine() {
+	g.setSTATLine(g.statSignal(g.stat))
+}
+
+func (g *GPU) setSTATLine(line bool) {
+	if line && !g.statLine {
+		g.irq.SetIRQ(irq.LCDSFlag)
+	}
+	g.statLine = line
+}
+
+// startTransfer enters mode 3 with sprites found in OAM scan.
+func (g *GPU) startTransfer() {
+	g.searchOAM()
+	g.lcdOnLine = false
+	g.mode = TransferingData
+	g.lx = 0
+	g.bgFIFO.clear()
+	g.objFIFO.clear()
+	g.fetcher.reset(false)
+	g.discard = g.scrollX & 7
+	g.stall = transferStartDelay
+	g.spriteFetched = 0
+	g.spriteTile = -1
+	if g.ly == uint(g.windowY) {
+		g.wyTriggered = true
+	}
+	if g.windowNextLine {
+		g.windowNextLine = false
+		if g.windowEnabled() && g.wyTriggered {
+			g.startWindow()
+		}
+	}
+}
+
+// transfer outputs at most one pixel per dot.
+// Mode 3 is lengthened when fetcher or sprites stall the FIFO.
+func (g *GPU) transfer() {
+	if g.stall > 0 {
+		g.stall--
 		return
 	}
-	if g.clock >= CyclePerLine {
-		if g.ly == constants.ScreenHeight {
-			g.buildSprites()
-			g.irq.SetIRQ(irq.VerticalBlankFlag)
-			if g.vBlankInterruptEnabled() {
-				g.irq.SetIRQ(irq.LCDSFlag)
-			}
-		} else if g.ly >= constants.ScreenHeight+LCDVBlankHeight {
-			g.ly = 0
-			g.buildBGTile()
-		} else if g.ly < constants.ScreenHeight {
-			g.buildBGTile()
-			if g.windowEnabled() {
-				g.buildWindowTile()
+	if g.fetchSprite() {
+		return
+	}
+	if !g.fetcher.window && g.windowTriggered() {
+		g.startWindow()
+		switch {
+		case g.windowX == 0:
+			// The window is checked while fine scroll pixels are discarded,
+			// so it stutters by SCX & 7 pixels.
+			g.discard = 7 - g.scrollX&7
+		case g.windowX < 7:
+			g.discard = 7 - g.windowX
+		case g.windowX == 166:
+			// The window also spans the whole next line.
+			g.windowNextLine = true
+		}
+	}
+	g.tickFetcher()
+	if g.bgFIFO.size == 0 {
+		return
+	}
+	p := g.bgFIFO.pop()
+	if g.discard > 0 {
+		g.discard--
+		return
+	}
+	// LCDC bit 0 disables BG on DMG and only BG priority in CGB mode.
+	if !g.cgb && !g.bgEnabled() {
+		p.color = 0
+	}
+	rgba, index := g.getBGPalette(p), g.bgIndex(p)
+	bg, bgLayer := rgba, LayerBG
+	if p.window {
+		bgLayer = LayerWindow
+	}
+	src := Source{Layer: bgLayer}
+	if g.hiddenLayers&bgLayer != 0 {
+		p.color = 0
+		rgba, index = g.getBGPalette(p), g.bgIndex(p)
+		src.Layer = LayerNone
+	}
+	var sprite *objPixel
+	if g.objFIFO.size != 0 {
+		o := g.objFIFO.pop()
+		if o.color != 0 && g.spriteEnabled() {
+			sprite = &o
+			if g.hiddenLayers&LayerSprites == 0 && g.spriteOverBG(o, p) {
+				rgba, index = g.getSpritePalette(o), g.spriteIndex(o)
+				src = Source{Layer: LayerSprites, Sprite: byte(o.index)}
 			}
 		}
+	}
+	g.setPixel(g.lx, rgba, index)
+	if g.layers != nil {
+		g.recordLayers(g.lx, bgLayer, bg, sprite, src)
+	}
+	g.lx++
+	if g.lx == constants.ScreenWidth {
+		g.mode = HBlankMode
+		g.hblankSource = g.clock + hblankSTATDelay
+		if g.lineHook != nil {
+			g.lineHook(g.lineState(), g.bus)
+		}
+	}
+}
+
+// spriteOverBG reports whether the sprite pixel is drawn over the BG pixel.
+// Sprite with BG priority is drawn only over BG colour 0.
+// In CGB mode, BG map attribute also gives BG priority and
+// LCDC bit 0 makes sprites always drawn over BG.
+func (g *GPU) spriteOverBG(o objPixel, p bgPixel) bool {
+	if p.color == 0 || (g.cgb && !g.bgEnabled()) {
+		return true
+	}
+	return !o.behindBG && !p.priority
+}
 
-		if g.ly == uint(g.lyc) {
-			g.stat |= 0x04
-			if g.coincidenceInterruptEnabled() {
-				g.irq.SetIRQ(irq.LCDSFlag)
+// searchOAM selects up to 10 sprites on current line in OAM order.
+// Sprites out of the screen horizontally are also counted.
+func (g *GPU) searchOAM() {
+	g.lineSpriteNum = 0
+	height := 8
+	if g.longSprite() {
+		height = 16
+	}
+	for i := 0; i < spriteNum && g.lineSpriteNum < lineSpriteMax; i++ {
+		y := int(g.bus.ReadOAM(OAMSTART+types.Word(i*4))) - 16
+		if int(g.ly) < y || int(g.ly) >= y+height {
+			continue
+		}
+		g.lineSprites[g.lineSpriteNum] = lineSprite{
+			index: i,
+			x:     g.bus.ReadOAM(OAMSTART + types.Word(i*4+1)),
+		}
+		g.lineSpriteNum++
+	}
+}
+
+// fetchSprite stalls the FIFO when a sprite starts at current pixel.
+// https://gbdev.io/pandocs/Rendering.html#obj-penalty-algorithm
+func (g *GPU) fetchSprite() bool {
+	if !g.spriteEnabled() {
+		return false
+	}
+	for i := 0; i < g.lineSpriteNum; i++ {
+		s := g.lineSprites[i]
+		if g.spriteFetched&(1<<uint(i)) != 0 || uint(s.x) > g.lx+8 {
+			continue
+		}
+		g.spriteFetched |= 1 << uint(i)
+		penalty := uint(6)
+		offset := int(s.x) + int(g.scrollX)
+		if g.fetcher.window {
+			offset = int(s.x) + 0xFF - int(g.windowX)
+		}
+		if tile := offset / 8; tile != g.spriteTile {
+			g.spriteTile = tile
+			if o := uint(offset & 7); o < 5 {
+				penalty += 5 - o
 			}
-		} else {
-			g.stat &= 0xFB
 		}
-		g.ly++
-		g.clock -= CyclePerLine
+		g.loadSprite(s)
+		// This dot is a part of the penalty.
+		g.stall = penalty - 1
+		return true
 	}
+	return false
 }
 
-func (g *GPU) lcdEnabled() bool {
-	return (g.lcdc & 0x80) == 0x80
+// windowTriggered reports whether the window starts at current pixel.
+// The window is shown after LY matches WY in the frame and
+// it is triggered again on the same line when WX matches after it is disabled.
+func (g *GPU) windowTriggered() bool {
+	if !g.windowEnabled() || !g.wyTriggered {
+		return false
+	}
+	if g.windowX < 7 {
+		return g.lx == 0
+	}
+	return g.lx+7 == uint(g.windowX)
 }
 
-func (g *GPU) longSprite() bool {
-	return (g.lcdc & 0x04) == 0x04
+// startWindow switches the fetcher to the window.
+func (g *GPU) startWindow() {
+	g.bgFIFO.clear()
+	g.fetcher.reset(true)
+	g.discard = 0
+	g.windowDrawn = true
 }
 
-func (g *GPU) coincidenceInterruptEnabled() bool {
-	return (g.stat & 0x40) == 0x40
+// leaveWindow switches the fetcher back to BG when the window is disabled on the line.
+func (g *GPU) leaveWindow() {
+	f := &g.fetcher
+	f.window = false
+	f.x = (g.lx + uint(g.bgFIFO.size) + uint(g.scrollX&7)) / 8
 }
 
-func (g *GPU) vBlankInterruptEnabled() bool {
-	return (g.stat & 0x10) == 0x10
+func (g *GPU) setPixel(x uint, rgba color.RGBA, index IndexedPixel) {
+	if g.blank {
+		return
+	}
+	g.indexed[g.ly*constants.ScreenWidth+x] = index
+	base := (g.ly*constants.ScreenWidth + x) * 4
+	g.imageData[base] = rgba.R
+	g.imageData[base+1] = rgba.G
+	g.imageData[base+2] = rgba.B
+	g.imageData[base+3] = rgba.A
+}
+
+func (g *GPU) lcdEnabled() bool {
+	return (g.lcdc & 0x80) == 0x80
 }
 
-func (g *GPU) hblankInterruptEnabled() bool {
-	return (g.stat & 0x08) == 0x08
+func (g *GPU) bgEnabled() bool {
+	return (g.lcdc & 0x01) == 0x01
+}
+
+func (g *GPU) spriteEnabled() bool {
+	return (g.lcdc & 0x02) == 0x02
+}
+
+func (g *GPU) longSprite() bool {
+	return (g.lcdc & 0x04) == 0x04
 }
 
 func (g *GPU) Read(addr types.Word) byte {
@@ -189,42 +661,32 @@ func (g *GPU) Read(addr types.Word) byte {
 	case LCDC:
 		return g.lcdc
 	case STAT:
-		return g.stat&0xF8 | (byte(g.mode)) | 0x80
+		return g.stat&0xFC | (byte(g.mode)) | 0x80
 	case SCROLLX:
 		return g.scrollX
 	case SCROLLY:
 		return g.scrollY
 	case LY:
 		return byte(g.ly)
+	case LYC:
+		return g.lyc
 	case BGP:
 		return g.bgPalette
 	case OBP0:
 		return g.objPalette0
 	case OBP1:
 		return g.objPalette1
+	case DMA:
+		return g.dma
 	case WX:
 		return g.windowX
 	case WY:
 		return g.windowY
+	case BCPS, BCPD, OCPS, OCPD, OPRI:
+		return g.readCGB(addr)
 	}
-	return 0x00
-}
-
-func (g *GPU) updateMode() {
-	switch {
-	case g.ly > constants.ScreenHeight:
-		g.mode = VBlankMode
-	case g.clock <= 80:
-		g.mode = SearchingOAMMode
-	case g.clock >= 167 && g.clock <= 291:
-		g.mode = TransferingData
-	default:
-		g.mode = HBlankMode
-		if g.hblankInterruptEnabled() {
-			g.irq.SetIRQ(irq.LCDSFlag)
-		}
-	}
-
+	// Unused registers
+	return 0xFF
 }
 
 func (g *GPU) windowEnabled() bool {
@@ -255,10 +717,24 @@ func (g *GPU) getTileDataAddr() types.Word {
 func (g *GPU) Write(addr types.Word, data byte) {
 	switch addr {
 	case LCDC:
+		enabled := g.lcdEnabled()
 		g.lcdc = data
+		switch {
+		case enabled && !g.lcdEnabled():
+			g.turnOff()
+		case !enabled && g.lcdEnabled():
+			g.turnOn()
+		}
 	case STAT:
+		// On DMG, STAT behaves as if all bits were set for a cycle on write,
+		// so HBlank, VBlank and LYC sources can request the interrupt.
+		// CGB does not have this quirk even in DMG compatible mode.
+		if !g.cgbHardware {
+			g.setSTATLine(g.statSignal(statHBlank | statVBlank | statLYC))
+		}
 		// bit2-0 are flags
-		g.stat = (g.stat & 0x07) | data
+		g.stat = (g.stat & 0x07) | (data & 0x78)
+		g.updateSTATLine()
 	case SCROLLX:
 		g.scrollX = data
 	case SCROLLY:
@@ -267,6 +743,10 @@ func (g *GPU) Write(addr types.Word, data byte) {
 		g.ly = 0
 	case LYC:
 		g.lyc = data
+		if g.lcdEnabled() {
+			g.compareLY()
+			g.updateSTATLine()
+		}
 	case BGP:
 		g.bgPalette = data
 	case OBP0:
@@ -274,12 +754,13 @@ func (g *GPU) Write(addr types.Word, data byte) {
 	case OBP1:
 		g.objPalette1 = data
 	case DMA:
-		g.oamDMAStarted = true
-		g.oamDMAStartAddr = types.Word(data) * 0x100
+		g.dma = data
 	case WX:
 		g.windowX = data
 	case WY:
 		g.windowY = data
+	case BCPS, BCPD, OCPS, OCPD, OPRI:
+		g.writeCGB(addr, data)
 	}
 }
 
@@ -288,180 +769,36 @@ func (g *GPU) GetImageData() []byte {
 	return g.imageData
 }
 
-func (g *GPU) DMAStarted() bool {
-	return g.oamDMAStarted
-}
-
-func (g *GPU) Transfer() {
-	for i := 0; i < 0xA0; i++ {
-		data := g.bus.ReadByte(g.oamDMAStartAddr + types.Word(i))
-		g.bus.WriteByte(OAMSTART+types.Word(i), data)
-	}
-	g.oamDMAStarted = false
-}
-
-func (g *GPU) buildSprites() {
-	for i := 0; i < spriteNum; i++ {
-		offsetY := int(g.bus.ReadByte(types.Word(OAMSTART+i*4))) - 16
-		offsetX := int(g.bus.ReadByte(types.Word(OAMSTART+i*4+1))) - 8
-		tileID := g.bus.ReadByte(types.Word(OAMSTART + i*4 + 2))
-		config := types.Word(g.bus.ReadByte(types.Word(OAMSTART + i*4 + 3)))
-		// aboveBG := config&0x80 == 0
-		yFlip := config&0x40 != 0
-		xFlip := config&0x20 != 0
-		isPallette1 := config&0x10 != 0
-		height := 8
-		if g.longSprite() {
-			height = 16
-			// LSB is ignored (treated as 0) in 8x16 mode.)
-			tileID = tileID & 0xFE
-		}
-		for x := 0; x < 8; x++ {
-			for y := 0; y < height; y++ {
-				if offsetX+x < 0 || offsetX+x >= constants.ScreenWidth {
-					continue
-				}
-				if offsetY+y < 0 || offsetY+y >= constants.ScreenHeight {
-					continue
-				}
-
-				paletteID := g.getSpritePaletteID(int(tileID), x, uint(y))
-				adjustedX := x
-				if xFlip {
-					adjustedX = 7 - x
-				}
-				adjustedY := y
-				if yFlip {
-					adjustedY = 7 - y
-				}
-				var c byte
-				if isPallette1 {
-					c = (g.objPalette1 >> (paletteID * 2)) & 0x03
-				} else {
-					c = (g.objPalette0 >> (paletteID * 2)) & 0x03
-				}
-				if paletteID != 0 {
-					rgba := g.getPalette(c)
-					base := (uint(offsetY+adjustedY)*constants.ScreenWidth + uint(adjustedX+offsetX)) * 4
-					g.imageData[base] = rgba.R
-					g.imageData[base+1] = rgba.G
-					g.imageData[base+2] = rgba.B
-					g.imageData[base+3] = rgba.A
-				}
-			}
-		}
-	}
-}
-
-func (g *GPU) buildBGTile() {
-	var tileID int
-	for x := 0; x < constants.ScreenWidth; x++ {
-		tileY := ((g.ly + uint(g.scrollY)) % 0x100) / 8 * 32
-		tileID = g.getTileID(tileY, uint(x+int(g.scrollX))/8%32, g.getBGTilemapAddr())
-		paletteID := g.getBGPaletteID(tileID, int(g.scrollX%8)+x, (g.ly+uint(g.scrollY))%8)
-		rgba := g.getBGPalette(uint(paletteID))
-		base := ((g.ly)*constants.ScreenWidth + uint(x)) * 4
-		g.imageData[base] = rgba.R
-		g.imageData[base+1] = rgba.G
-		g.imageData[base+2] = rgba.B
-		g.imageData[base+3] = rgba.A
-	}
-}
-
-func (g *GPU) buildWindowTile() {
-	var tileID int
-	if (g.windowX < 0 && g.windowX >= 167) && (g.windowY < 0 && g.windowY >= 144) {
-		return
-	}
-	if g.ly < uint(g.windowY) {
-		return
-	}
-	for x := 0; x < constants.ScreenWidth; x++ {
-		offsetX := uint(g.windowX - 7)
-		if x < int(offsetX) {
-			continue
-		}
-		tileY := (g.ly - uint(g.windowY)) / 8 * 32
-		tileID = g.getTileID(tileY, uint(x-int(offsetX))/8, g.getWindowTilemapAddr())
-		paletteID := g.getBGPaletteID(tileID, int(x-int(offsetX)), (g.ly-uint(g.windowY))%8)
-
-		rgba := g.getBGPalette(uint(paletteID))
-		base := ((g.ly)*constants.ScreenWidth + uint(x)) * 4
-		g.imageData[base] = rgba.R
-		g.imageData[base+1] = rgba.G
-		g.imageData[base+2] = rgba.B
-		g.imageData[base+3] = rgba.A
-	}
-}
-
 func (g *GPU) tileData0Selected() bool {
 	return g.lcdc&0x10 != 0x10
 }
 
-func (g *GPU) getSpritePaletteID(tileID int, x int, y uint) byte {
-	x = x % 8
-	addr := types.Word(tileID * 0x10)
-	base := types.Word(TILEDATA1 + addr + types.Word(y*2))
-	l1 := g.bus.ReadByte(base)
-	l2 := g.bus.ReadByte(base + 1)
-	paletteID := byte(0)
-	if l1&(0x01<<(7-uint(x))) != 0 {
-		paletteID = 1
-	}
-	if l2&(0x01<<(7-uint(x))) != 0 {
-		paletteID += 2
-	}
-	return paletteID
-}
-
-func (g *GPU) getBGPaletteID(tileID int, x int, y uint) byte {
-	x = x % 8
-	var addr types.Word
+// tileDataAddr returns address of BG and window tile.
+func (g *GPU) tileDataAddr(tileID byte) types.Word {
 	// In the first case, patterns are numbered with unsigned numbers from 0 to 255 (i.e.
 	// 	pattern #0 lies at address $8000). In the second case,
 	// 	patterns have signed numbers from -128 to 127 (i.e.
 	// 	pattern #0 lies at address $9000). The Tile Data Table
 	// 	address for the background can be selected via LCDC	register.
 	if g.tileData0Selected() {
-		addr = types.Word((int(int8(tileID)) + 128) * 0x10)
-	} else {
-		addr = types.Word(tileID * 0x10)
-	}
-	base := types.Word(g.getTileDataAddr() + addr + types.Word(y*2))
-	l1 := g.bus.ReadByte(base)
-	l2 := g.bus.ReadByte(base + 1)
-	paletteID := byte(0)
-	if l1&(0x01<<(7-uint(x))) != 0 {
-		paletteID = 1
-	}
-	if l2&(0x01<<(7-uint(x))) != 0 {
-		paletteID += 2
+		return g.getTileDataAddr() + types.Word((int(int8(tileID))+128)*0x10)
 	}
-	return paletteID
+	return g.getTileDataAddr() + types.Word(tileID)*0x10
 }
 
-func (g *GPU) getTileID(tileY, lineOffset uint, offsetAddr types.Word) int {
-	addr := types.Word(tileY) + types.Word(lineOffset) + offsetAddr
-	id := byte(g.bus.ReadByte(addr))
-	return int(id)
-
-}
-
-func (g *GPU) getBGPalette(n uint) color.RGBA {
-	c := (g.bgPalette >> (n * 2)) & 0x03
-	return g.getPalette(c)
+func (g *GPU) getBGPalette(p bgPixel) color.RGBA {
+	if g.cgb {
+		return g.bgColors.color(p.palette, p.color)
+	}
+	return g.palettes.BG[g.bgIndex(p).Shade()]
 }
 
-func (g *GPU) getPalette(c byte) color.RGBA {
-	switch c {
-	case 0:
-		return color.RGBA{175, 197, 160, 255}
-	case 1:
-		return color.RGBA{93, 147, 66, 255}
-	case 2:
-		return color.RGBA{22, 63, 48, 255}
-	case 3:
-		return color.RGBA{0, 40, 0, 255}
+func (g *GPU) getSpritePalette(o objPixel) color.RGBA {
+	if g.cgb {
+		return g.objColors.color(o.palette, o.color)
+	}
+	if o.obp1 {
+		return g.palettes.OBP1[g.spriteIndex(o).Shade()]
 	}
-	panic("unhandled color number detected.")
+	return g.palettes.OBP0[g.spriteIndex(o).Shade()]
 }
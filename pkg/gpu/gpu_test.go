@@ -6,6 +6,7 @@ import (
 	"github.com/bokuweb/gopher-boy/pkg/constants"
 	"github.com/bokuweb/gopher-boy/pkg/interrupt"
 	"github.com/bokuweb/gopher-boy/pkg/mocks"
+	"github.com/bokuweb/gopher-boy/pkg/types"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -21,12 +22,388 @@ func TestLY(t *testing.T) {
 	assert := assert.New(t)
 	g := setup()
 	for y := 0; y < int(constants.ScreenHeight+LCDVBlankHeight+10); y++ {
-		if y == int(constants.ScreenHeight+LCDVBlankHeight) {
-			assert.Equal(uint8(0x9a), g.Read(LY))
-		} else {
-			assert.Equal(byte(y%int(constants.ScreenHeight+LCDVBlankHeight)), g.Read(LY), y)
+		assert.Equal(byte(y%int(constants.ScreenHeight+LCDVBlankHeight)), g.Read(LY), y)
+		g.Step(CyclePerLine)
+	}
+}
+
+// LY wraps from 153 to 0 and never reads 154 (0x9a).
+func TestLYWrap(t *testing.T) {
+	assert := assert.New(t)
+	g := setup()
+	g.Step(CyclePerLine * uint(constants.ScreenHeight+LCDVBlankHeight-1))
+	assert.Equal(byte(0x99), g.Read(LY))
+	for dot := uint(0); dot < CyclePerLine; dot++ {
+		assert.NotEqual(byte(0x9a), g.Read(LY), dot)
+		g.Step(1)
+	}
+	assert.Equal(byte(0x00), g.Read(LY))
+}
+
+// hblankStart returns the dot HBlank starts on line 0.
+func hblankStart(g *GPU) uint {
+	for dot := uint(0); dot < CyclePerLine; dot++ {
+		if g.Read(STAT)&0x03 == HBlankMode && dot > oamScanDots {
+			return dot
 		}
+		g.Step(1)
+	}
+	return 0
+}
 
-		g.Step(CyclePerLine)
+func TestMode3Length(t *testing.T) {
+	tests := []struct {
+		name string
+		init func(g *GPU, b *mocks.MockBus)
+		dots uint
+	}{
+		{"plain", func(g *GPU, b *mocks.MockBus) {}, 253},
+		{"SCX=3", func(g *GPU, b *mocks.MockBus) { g.Write(SCROLLX, 3) }, 256},
+		{"SCX=8", func(g *GPU, b *mocks.MockBus) { g.Write(SCROLLX, 8) }, 253},
+		{"window", func(g *GPU, b *mocks.MockBus) {
+			g.Write(LCDC, 0xB1)
+			g.Write(WX, 7+80)
+		}, 259},
+		{"sprite at X=0", func(g *GPU, b *mocks.MockBus) {
+			g.Write(LCDC, 0x93)
+			b.SetMemory(OAMSTART, []byte{16, 0})
+		}, 264},
+		{"sprites in a tile", func(g *GPU, b *mocks.MockBus) {
+			g.Write(LCDC, 0x93)
+			b.SetMemory(OAMSTART, []byte{16, 8 + 80, 0, 0, 16, 8 + 81})
+		}, 253 + 11 + 6},
+		{"sprite disabled", func(g *GPU, b *mocks.MockBus) {
+			b.SetMemory(OAMSTART, []byte{16, 0})
+		}, 253},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := &mocks.MockBus{}
+			g := NewGPU()
+			g.Init(b, interrupt.NewInterrupt())
+			tt.init(g, b)
+			assert.Equal(t, tt.dots, hblankStart(g))
+		})
+	}
+}
+
+func TestMidScanlineBGP(t *testing.T) {
+	assert := assert.New(t)
+	g := setup()
+	g.Write(BGP, 0x00)
+	// First pixel is output after 9 dots of mode 3.
+	g.Step(transferStart + 9 + 80)
+	g.Write(BGP, 0xFF)
+	g.Step(CyclePerLine)
+	img := g.GetImageData()
+	assert.Equal(g.palettes.BG[0].R, img[79*4])
+	assert.Equal(g.palettes.BG[3].R, img[80*4])
+}
+
+// setupSprites returns GPU with sprites in OAM.
+// Tile 1 is filled with colour 3 and tile 2 with colour 2.
+func setupSprites(lcdc byte, oam ...byte) (*GPU, *mocks.MockBus) {
+	b := &mocks.MockBus{}
+	g := NewGPU()
+	g.Init(b, interrupt.NewInterrupt())
+	g.Write(LCDC, lcdc)
+	g.Write(BGP, 0xE4)
+	g.Write(OBP0, 0xE4)
+	for i := 0; i < 16; i += 2 {
+		b.SetMemory(TILEDATA1+0x10+types.Word(i), []byte{0xFF, 0xFF})
+		b.SetMemory(TILEDATA1+0x20+types.Word(i), []byte{0x00, 0xFF})
+	}
+	b.SetMemory(OAMSTART, oam)
+	return g, b
+}
+
+// shade returns colour number of the pixel on line 0.
+func shade(g *GPU, x int) byte {
+	r := g.GetImageData()[x*4]
+	for c := byte(0); c < 4; c++ {
+		if g.palettes.BG[c].R == r {
+			return c
+		}
+	}
+	return 0xFF
+}
+
+func TestSpriteLimit(t *testing.T) {
+	assert := assert.New(t)
+	var oam []byte
+	for i := 0; i < 11; i++ {
+		oam = append(oam, 16, byte(8+i*8), 1, 0)
+	}
+	g, _ := setupSprites(0x93, oam...)
+	g.Step(CyclePerLine)
+	for i := 0; i < 10; i++ {
+		assert.Equal(byte(3), shade(g, i*8), i)
+	}
+	assert.Equal(byte(0), shade(g, 80))
+}
+
+func TestSpriteBGPriority(t *testing.T) {
+	assert := assert.New(t)
+	g, b := setupSprites(0x93, 16, 8, 1, 0x80)
+	// Left half of BG tile 0 is colour 1.
+	b.SetMemory(TILEDATA1, []byte{0xF0, 0x00})
+	g.Step(CyclePerLine)
+	assert.Equal(byte(1), shade(g, 3))
+	assert.Equal(byte(3), shade(g, 4))
+}
+
+func TestSpriteXPriority(t *testing.T) {
+	assert := assert.New(t)
+	g, _ := setupSprites(0x93, 16, 12, 2, 0, 16, 8, 1, 0)
+	g.Step(CyclePerLine)
+	assert.Equal(byte(3), shade(g, 7))
+	assert.Equal(byte(2), shade(g, 8))
+
+	g, _ = setupSprites(0x93, 16, 8, 2, 0, 16, 8, 1, 0)
+	g.Step(CyclePerLine)
+	assert.Equal(byte(2), shade(g, 0))
+}
+
+func TestLongSpriteYFlip(t *testing.T) {
+	assert := assert.New(t)
+	g, b := setupSprites(0x97, 16, 8, 4, 0x40)
+	// Last row of tile 5 is colour 3.
+	b.SetMemory(TILEDATA1+0x50+14, []byte{0xFF, 0xFF})
+	g.Step(CyclePerLine)
+	assert.Equal(byte(3), shade(g, 0))
+}
+
+func setupWithIRQ() (*GPU, *interrupt.Interrupt) {
+	g := NewGPU()
+	irq := interrupt.NewInterrupt()
+	g.Init(&mocks.MockBus{}, irq)
+	return g, irq
+}
+
+// statIRQ reports LCD STAT interrupt request and clears it.
+func statIRQ(irq *interrupt.Interrupt) bool {
+	requested := irq.IF&interrupt.LCDSFlag != 0
+	irq.IF = 0
+	return requested
+}
+
+func TestSTATHBlankRisingEdge(t *testing.T) {
+	assert := assert.New(t)
+	g, irq := setupWithIRQ()
+	g.Write(STAT, statHBlank)
+	statIRQ(irq)
+	g.Step(260)
+	assert.True(statIRQ(irq))
+	g.Step(100)
+	assert.False(statIRQ(irq))
+}
+
+func TestSTATMode2(t *testing.T) {
+	assert := assert.New(t)
+	g, irq := setupWithIRQ()
+	g.Write(STAT, statOAM)
+	statIRQ(irq)
+	g.Step(CyclePerLine + oamScanStart)
+	assert.True(statIRQ(irq))
+	// Mode 2 source fires at the start of VBlank too.
+	g.Step(CyclePerLine * (constants.ScreenHeight - 1))
+	assert.True(statIRQ(irq))
+	g.Step(CyclePerLine)
+	assert.False(statIRQ(irq))
+}
+
+func TestSTATBlocking(t *testing.T) {
+	assert := assert.New(t)
+	g, irq := setupWithIRQ()
+	g.Write(STAT, statHBlank|statOAM)
+	g.Step(260)
+	statIRQ(irq)
+	// Line stays high from HBlank to mode 2 of the next line.
+	g.Step(CyclePerLine - 260 + 10)
+	assert.False(statIRQ(irq))
+}
+
+func TestSTATLYC(t *testing.T) {
+	assert := assert.New(t)
+	g, irq := setupWithIRQ()
+	g.Write(LYC, 2)
+	g.Write(STAT, statLYC)
+	statIRQ(irq)
+	g.Step(CyclePerLine)
+	assert.False(statIRQ(irq))
+	// LY=LYC is compared when the line starts after LY is incremented.
+	g.Step(CyclePerLine)
+	assert.Equal(byte(0x00), g.Read(STAT)&0x04)
+	g.Step(oamScanStart)
+	assert.True(statIRQ(irq))
+	assert.Equal(byte(0x04), g.Read(STAT)&0x04)
+}
+
+func TestSTATWriteQuirk(t *testing.T) {
+	assert := assert.New(t)
+	g, irq := setupWithIRQ()
+	g.Step(260)
+	statIRQ(irq)
+	g.Write(STAT, 0x00)
+	assert.True(statIRQ(irq))
+}
+
+func TestLCDOff(t *testing.T) {
+	assert := assert.New(t)
+	g := setup()
+	g.Step(CyclePerLine*10 + 100)
+	g.Write(LCDC, 0x11)
+	assert.True(g.ScreenBlank())
+	assert.Equal(byte(0), g.Read(LY))
+	assert.Equal(HBlankMode, g.Read(STAT)&0x03)
+	g.Step(CyclePerLine * 3)
+	assert.Equal(byte(0), g.Read(LY))
+	assert.Equal(g.palettes.BG[0].R, g.GetImageData()[0])
+}
+
+func TestLCDOn(t *testing.T) {
+	assert := assert.New(t)
+	g, irq := setupWithIRQ()
+	g.Write(LCDC, 0x11)
+	g.Write(STAT, statLYC)
+	statIRQ(irq)
+	g.Write(LCDC, 0x91)
+	// LY=LYC=0 is detected as soon as LCD is turned on.
+	assert.True(statIRQ(irq))
+	// The first line has no OAM scan.
+	g.Step(transferStart - lcdOnLineOffset - 1)
+	assert.Equal(HBlankMode, g.Read(STAT)&0x03)
+	g.Step(1)
+	assert.Equal(TransferingData, g.Read(STAT)&0x03)
+	g.Step(CyclePerLine - transferStart)
+	assert.Equal(byte(1), g.Read(LY))
+	// STAT reads mode 0 until OAM scan starts.
+	assert.Equal(HBlankMode, g.Read(STAT)&0x03)
+	g.Step(oamScanStart)
+	assert.Equal(SearchingOAMMode, g.Read(STAT)&0x03)
+	assert.True(g.ScreenBlank())
+	g.Step(CyclePerLine * constants.ScreenHeight)
+	assert.False(g.ScreenBlank())
+}
+
+// The first line after LCD is turned on is 4 dots shorter and VRAM and OAM
+// are accessible until mode 3.
+func TestLCDOnFirstLine(t *testing.T) {
+	assert := assert.New(t)
+	g := setup()
+	g.Write(LCDC, 0x11)
+	g.Write(LCDC, 0x91)
+	assert.True(g.OAMAccessible(false))
+	assert.True(g.OAMAccessible(true))
+	g.Step(transferStart - lcdOnLineOffset - 1)
+	assert.True(g.OAMAccessible(false))
+	assert.True(g.VRAMAccessible(false))
+	g.Step(1)
+	assert.False(g.OAMAccessible(false))
+	assert.False(g.VRAMAccessible(true))
+	g.Step(CyclePerLine - transferStart - 1)
+	assert.Equal(byte(0), g.Read(LY))
+	g.Step(1)
+	assert.Equal(byte(1), g.Read(LY))
+}
+
+// LY=LYC flag keeps its value while LCD is off.
+func TestLCDOffLYCFlag(t *testing.T) {
+	assert := assert.New(t)
+	g := setup()
+	g.Step(oamScanStart)
+	assert.Equal(byte(0x04), g.Read(STAT)&0x04)
+	g.Write(LCDC, 0x11)
+	g.Write(LYC, 1)
+	assert.Equal(byte(0x04), g.Read(STAT)&0x04)
+}
+
+// setupWindow returns GPU showing the window with tile 1 in 9C00 map.
+// BG shows tile 0 which is colour 0.
+func setupWindow(wx byte) *GPU {
+	g, b := setupSprites(0xF1)
+	for i := 0; i < 0x400; i++ {
+		b.SetMemory(TILEMAP1+types.Word(i), []byte{1})
+	}
+	g.Write(WX, wx)
+	return g
+}
+
+func TestWindowWX166(t *testing.T) {
+	assert := assert.New(t)
+	g := setupWindow(166)
+	g.Step(CyclePerLine)
+	assert.Equal(byte(0), shade(g, 158))
+	assert.Equal(byte(3), shade(g, 159))
+	// The window spans the whole next line.
+	g.Step(CyclePerLine)
+	assert.Equal(g.palettes.BG[3].R, g.GetImageData()[constants.ScreenWidth*4])
+}
+
+func TestWindowWX0(t *testing.T) {
+	assert := assert.New(t)
+	g, b := setupSprites(0xF1)
+	// Window tile 2 has only the first pixel of each row.
+	b.SetMemory(TILEDATA1+0x20, []byte{0x80, 0x80})
+	for i := 0; i < 32; i++ {
+		b.SetMemory(TILEMAP1+types.Word(i), []byte{2})
 	}
+	g.Write(WX, 0)
+	g.Step(CyclePerLine)
+	assert.Equal(byte(3), shade(g, 1))
+
+	g, b = setupSprites(0xF1)
+	b.SetMemory(TILEDATA1+0x20, []byte{0x80, 0x80})
+	for i := 0; i < 32; i++ {
+		b.SetMemory(TILEMAP1+types.Word(i), []byte{2})
+	}
+	g.Write(WX, 0)
+	g.Write(SCROLLX, 3)
+	g.Step(CyclePerLine)
+	assert.Equal(byte(0), shade(g, 1))
+	assert.Equal(byte(3), shade(g, 4))
+}
+
+func TestWindowLineCounter(t *testing.T) {
+	assert := assert.New(t)
+	g, b := setupSprites(0xF1)
+	// Window map row 0 is tile 1 and row 1 is tile 2.
+	for i := 0; i < 32; i++ {
+		b.SetMemory(TILEMAP1+types.Word(i), []byte{1})
+		b.SetMemory(TILEMAP1+0x20+types.Word(i), []byte{2})
+	}
+	g.Write(WX, 7)
+	g.Step(CyclePerLine * 4)
+	g.Write(LCDC, 0xD1)
+	g.Step(CyclePerLine * 8)
+	g.Write(LCDC, 0xF1)
+	g.Step(CyclePerLine)
+	// Line 12 draws window line 4.
+	assert.Equal(g.palettes.BG[3].R, g.GetImageData()[12*constants.ScreenWidth*4])
+}
+
+func TestWindowWYLatch(t *testing.T) {
+	assert := assert.New(t)
+	g := setupWindow(7)
+	g.Write(WY, 50)
+	g.Step(CyclePerLine * 30)
+	// WY has been never matched in this frame.
+	g.Write(WY, 10)
+	g.Step(CyclePerLine * 11)
+	assert.Equal(g.palettes.BG[0].R, g.GetImageData()[40*constants.ScreenWidth*4])
+}
+
+func TestWindowRetrigger(t *testing.T) {
+	assert := assert.New(t)
+	g := setupWindow(7 + 16)
+	// Disable the window in the middle of line 0 and enable it again.
+	g.Step(oamScanDots + 12 + 40)
+	g.Write(LCDC, 0xD1)
+	g.Step(16)
+	g.Write(LCDC, 0xF1)
+	g.Write(WX, 7+100)
+	g.Step(CyclePerLine)
+	assert.Equal(byte(3), shade(g, 16))
+	assert.Equal(byte(0), shade(g, 80))
+	assert.Equal(byte(3), shade(g, 100))
 }
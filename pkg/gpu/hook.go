@@ -0,0 +1,39 @@
+package gpu
+
+import "github.com/bokuweb/gopher-boy/pkg/interfaces/bus"
+
+// LineState is PPU registers when a line enters HBlank.
+type LineState struct {
+	LY   byte
+	LCDC byte
+	SCX  byte
+	SCY  byte
+	WX   byte
+	WY   byte
+	BGP  byte
+	OBP0 byte
+	OBP1 byte
+}
+
+// LineHook is called at HBlank of each visible line.
+// mem reads VRAM and OAM, which CPU can also access in HBlank.
+type LineHook func(s LineState, mem bus.VideoAccessor)
+
+// SetLineHook sets h called at HBlank of each line, nil removes it.
+func (g *GPU) SetLineHook(h LineHook) {
+	g.lineHook = h
+}
+
+func (g *GPU) lineState() LineState {
+	return LineState{
+		LY:   byte(g.ly),
+		LCDC: g.lcdc,
+		SCX:  g.scrollX,
+		SCY:  g.scrollY,
+		WX:   g.windowX,
+		WY:   g.windowY,
+		BGP:  g.bgPalette,
+		OBP0: g.objPalette0,
+		OBP1: g.objPalette1,
+	}
+}
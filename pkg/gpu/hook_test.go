@@ -0,0 +1,47 @@
+package gpu
+
+import (
+	"testing"
+
+	"github.com/bokuweb/gopher-boy/pkg/constants"
+	"github.com/bokuweb/gopher-boy/pkg/interfaces/bus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLineHook(t *testing.T) {
+	assert := assert.New(t)
+	g, b := setupSprites(0x93, 16, 8, 1, 0)
+	var states []LineState
+	var tiles []byte
+	g.SetLineHook(func(s LineState, mem bus.VideoAccessor) {
+		states = append(states, s)
+		tiles = append(tiles, mem.ReadOAM(OAMSTART+2))
+		// Raster effect for the next line.
+		g.Write(SCROLLX, s.SCX+1)
+	})
+	b.SetMemory(OAMSTART+2, []byte{7})
+	g.Write(WX, 7)
+	g.Write(OBP1, 0x1B)
+	g.Step(CyclePerLine * 154)
+
+	assert.Len(states, int(constants.ScreenHeight))
+	for ly, s := range states {
+		assert.Equal(byte(ly), s.LY)
+		assert.Equal(byte(ly), s.SCX)
+	}
+	assert.Equal(LineState{LY: 1, LCDC: 0x93, SCX: 1, WX: 7, BGP: 0xE4, OBP0: 0xE4, OBP1: 0x1B}, states[1])
+	assert.Equal(byte(7), tiles[0])
+
+	// The hook is called at HBlank when the line enters mode 0.
+	states = nil
+	g.Step(oamScanDots + 160)
+	assert.Len(states, 0)
+	for g.Read(STAT)&0x03 != HBlankMode {
+		g.Step(1)
+	}
+	assert.Len(states, 1)
+
+	g.SetLineHook(nil)
+	g.Step(CyclePerLine)
+	assert.Len(states, 1)
+}
@@ -0,0 +1,83 @@
+package gpu
+
+import "github.com/bokuweb/gopher-boy/pkg/constants"
+
+// PaletteRegister is the palette register a pixel is drawn with.
+type PaletteRegister byte
+
+// Palette registers
+const (
+	PaletteBGP PaletteRegister = iota
+	PaletteOBP0
+	PaletteOBP1
+)
+
+// IndexedPixel is a pixel before the palette is applied.
+type IndexedPixel struct {
+	// Color is colour number 0-3 of the tile
+	Color byte
+	// Register is BGP for BG and window, and OBP0 or OBP1 for sprites
+	Register PaletteRegister
+	// Value is the register value when the pixel is drawn.
+	// In CGB mode, it is the colour palette number 0-7.
+	Value byte
+	// CGB is set for pixels drawn in CGB mode
+	CGB bool
+}
+
+// Shade returns shade number 0-3 the register maps the colour to.
+// In CGB mode, colour palettes are not shades, so the colour number is returned unchanged.
+func (p IndexedPixel) Shade() byte {
+	if p.CGB {
+		return p.Color
+	}
+	return (p.Value >> (p.Color * 2)) & 0x03
+}
+
+// IndexedImage returns colour number and the palette register of each pixel,
+// which does not depend on the palettes the screen is drawn with.
+func (g *GPU) IndexedImage() []IndexedPixel {
+	return g.indexed
+}
+
+// IndexedToRGBA converts indexed pixels to RGBA image with the palettes.
+// Pixels drawn in CGB mode are converted by their colour numbers, BG with p.BG and sprites with p.OBP0.
+func IndexedToRGBA(pixels []IndexedPixel, p Palettes) []byte {
+	buf := make([]byte, len(pixels)*4)
+	for i, px := range pixels {
+		palette := p.BG
+		switch px.Register {
+		case PaletteOBP0:
+			palette = p.OBP0
+		case PaletteOBP1:
+			palette = p.OBP1
+		}
+		c := palette[px.Shade()]
+		buf[i*4] = c.R
+		buf[i*4+1] = c.G
+		buf[i*4+2] = c.B
+		buf[i*4+3] = c.A
+	}
+	return buf
+}
+
+func newIndexedImage() []IndexedPixel {
+	return make([]IndexedPixel, constants.ScreenWidth*constants.ScreenHeight)
+}
+
+func (g *GPU) bgIndex(p bgPixel) IndexedPixel {
+	if g.cgb {
+		return IndexedPixel{Color: p.color, Register: PaletteBGP, Value: p.palette, CGB: true}
+	}
+	return IndexedPixel{Color: p.color, Register: PaletteBGP, Value: g.bgPalette}
+}
+
+func (g *GPU) spriteIndex(o objPixel) IndexedPixel {
+	switch {
+	case g.cgb:
+		return IndexedPixel{Color: o.color, Register: PaletteOBP0, Value: o.palette, CGB: true}
+	case o.obp1:
+		return IndexedPixel{Color: o.color, Register: PaletteOBP1, Value: g.objPalette1}
+	}
+	return IndexedPixel{Color: o.color, Register: PaletteOBP0, Value: g.objPalette0}
+}
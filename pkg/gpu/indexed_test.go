@@ -0,0 +1,43 @@
+package gpu
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIndexedImage(t *testing.T) {
+	assert := assert.New(t)
+	g, b := setupSprites(0x93, 16, 8, 2, 0x10)
+	g.Write(OBP1, 0x1B)
+	// BG tile 0 is colour 1.
+	b.SetMemory(TILEDATA1, []byte{0xFF, 0x00})
+	g.Step(CyclePerLine)
+	img := g.IndexedImage()
+	assert.Equal(IndexedPixel{Color: 2, Register: PaletteOBP1, Value: 0x1B}, img[0])
+	assert.Equal(byte(1), img[0].Shade())
+	assert.Equal(IndexedPixel{Color: 1, Register: PaletteBGP, Value: 0xE4}, img[8])
+
+	// Indexed image is independent of the palettes the screen is drawn with.
+	g.SetPalettes(mono(palette(0xFFFFFF, 0xAAAAAA, 0x555555, 0x000000)))
+	g.Step(CyclePerLine * 154)
+	assert.Equal(img[0], g.IndexedImage()[0])
+	rgba := IndexedToRGBA(g.IndexedImage(), DefaultPalettes)
+	assert.Equal(DefaultPalettes.OBP1[1].R, rgba[0])
+	assert.Equal(DefaultPalettes.BG[1].R, rgba[8*4])
+}
+
+func TestIndexedImageCGB(t *testing.T) {
+	assert := assert.New(t)
+	g, b := setupCGB(0x91)
+	// BG tile 0 is colour 2 with palette 3.
+	b.SetMemory(TILEDATA1, []byte{0x00, 0xFF})
+	b.MockVRAM1[TILEMAP0-0x8000] = 0x03
+	g.Step(CyclePerLine)
+	px := g.IndexedImage()[0]
+	assert.Equal(IndexedPixel{Color: 2, Register: PaletteBGP, Value: 3, CGB: true}, px)
+	// Colour palettes are not shades, so the colour number is kept.
+	assert.Equal(byte(2), px.Shade())
+	rgba := IndexedToRGBA(g.IndexedImage(), DefaultPalettes)
+	assert.Equal(DefaultPalettes.BG[2].R, rgba[0])
+}
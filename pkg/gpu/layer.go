@@ -0,0 +1,144 @@
+package gpu
+
+import (
+	"image/color"
+
+	"github.com/bokuweb/gopher-boy/pkg/constants"
+)
+
+// Layer is a layer of the screen, used as bit flags.
+type Layer byte
+
+// Layers
+const (
+	LayerBG Layer = 1 << iota
+	LayerWindow
+	LayerSprites
+)
+
+// LayerNone is source of pixels no layer draws, such as hidden layers.
+const LayerNone Layer = 0
+
+// Source is the layer and the sprite which produced a pixel.
+type Source struct {
+	Layer Layer
+	// Sprite is OAM index of the sprite when Layer is LayerSprites
+	Sprite byte
+}
+
+// layerBuffers are images of each layer and sources of the screen for debugging.
+// Pixels a layer does not draw are transparent in its image.
+type layerBuffers struct {
+	bg      []byte
+	window  []byte
+	sprites []byte
+	sources []Source
+}
+
+func newLayerBuffers() *layerBuffers {
+	size := constants.ScreenWidth * constants.ScreenHeight
+	return &layerBuffers{
+		bg:      make([]byte, size*4),
+		window:  make([]byte, size*4),
+		sprites: make([]byte, size*4),
+		sources: make([]Source, size),
+	}
+}
+
+func (l *layerBuffers) image(layer Layer) []byte {
+	switch layer {
+	case LayerBG:
+		return l.bg
+	case LayerWindow:
+		return l.window
+	case LayerSprites:
+		return l.sprites
+	}
+	return nil
+}
+
+func (l *layerBuffers) clear() {
+	for _, buf := range [][]byte{l.bg, l.window, l.sprites} {
+		for i := range buf {
+			buf[i] = 0
+		}
+	}
+	for i := range l.sources {
+		l.sources[i] = Source{}
+	}
+}
+
+func setRGBA(buf []byte, i uint, c color.RGBA) {
+	buf[i*4] = c.R
+	buf[i*4+1] = c.G
+	buf[i*4+2] = c.B
+	buf[i*4+3] = c.A
+}
+
+// SetLayerVisible shows or hides layers for debugging.
+// Hidden BG and window are drawn with colour 0 and hidden sprites are not drawn,
+// but they are still fetched so that timing of mode 3 is not changed.
+func (g *GPU) SetLayerVisible(layers Layer, visible bool) {
+	if visible {
+		g.hiddenLayers &^= layers
+	} else {
+		g.hiddenLayers |= layers
+	}
+}
+
+// LayerVisible reports whether all of the layers are visible.
+func (g *GPU) LayerVisible(layers Layer) bool {
+	return g.hiddenLayers&layers == 0
+}
+
+// EnableLayerBuffers starts or stops recording layer images and sources.
+// They are not recorded by default because it costs for every pixel.
+func (g *GPU) EnableLayerBuffers(enabled bool) {
+	switch {
+	case enabled && g.layers == nil:
+		g.layers = newLayerBuffers()
+	case !enabled:
+		g.layers = nil
+	}
+}
+
+// LayerImage returns RGBA image of the layer recorded since EnableLayerBuffers,
+// or nil when it is not enabled. The sprite layer also has sprites behind BG.
+func (g *GPU) LayerImage(layer Layer) []byte {
+	if g.layers == nil {
+		return nil
+	}
+	return g.layers.image(layer)
+}
+
+// Sources returns which layer and sprite produced each pixel of the screen,
+// or nil when layer buffers are not enabled.
+func (g *GPU) Sources() []Source {
+	if g.layers == nil {
+		return nil
+	}
+	return g.layers.sources
+}
+
+// recordLayers records the BG or window pixel, the sprite pixel if o is not nil and the source.
+func (g *GPU) recordLayers(x uint, bgLayer Layer, bg color.RGBA, o *objPixel, src Source) {
+	if g.blank {
+		return
+	}
+	l := g.layers
+	i := g.ly*constants.ScreenWidth + x
+	transparent := color.RGBA{}
+	if bgLayer == LayerWindow {
+		setRGBA(l.bg, i, transparent)
+		setRGBA(l.window, i, bg)
+	} else {
+		setRGBA(l.bg, i, bg)
+		setRGBA(l.window, i, transparent)
+	}
+	if o != nil {
+		setRGBA(l.sprites, i, g.getSpritePalette(*o))
+	} else {
+		setRGBA(l.sprites, i, transparent)
+	}
+	l.sources[i] = src
+}
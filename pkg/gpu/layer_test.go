@@ -0,0 +1,70 @@
+package gpu
+
+import (
+	"testing"
+
+	"github.com/bokuweb/gopher-boy/pkg/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLayerVisible(t *testing.T) {
+	assert := assert.New(t)
+	g, b := setupSprites(0x93, 16, 8, 2, 0)
+	// BG tile 0 is colour 1.
+	b.SetMemory(TILEDATA1, []byte{0xFF, 0x00})
+	g.SetLayerVisible(LayerSprites, false)
+	assert.False(g.LayerVisible(LayerSprites))
+	assert.True(g.LayerVisible(LayerBG | LayerWindow))
+	g.Step(CyclePerLine)
+	assert.Equal(byte(1), shade(g, 0))
+
+	g.SetLayerVisible(LayerBG|LayerSprites, true)
+	g.SetLayerVisible(LayerBG, false)
+	g.Step(CyclePerLine * 154)
+	assert.Equal(byte(2), shade(g, 0))
+	assert.Equal(byte(0), shade(g, 8))
+}
+
+func TestLayerHiddenTiming(t *testing.T) {
+	g, _ := setupSprites(0x93, 16, 0)
+	g.SetLayerVisible(LayerBG|LayerWindow|LayerSprites, false)
+	assert.Equal(t, uint(264), hblankStart(g))
+}
+
+func TestLayerBuffers(t *testing.T) {
+	assert := assert.New(t)
+	g, b := setupSprites(0xF3, 16, 8, 1, 0x80, 16, 20, 2, 0)
+	// Window tile 3 in 9C00 map is colour 1 from X=80.
+	for i := 0; i < 16; i += 2 {
+		b.SetMemory(TILEDATA1+0x30+types.Word(i), []byte{0xFF, 0x00})
+	}
+	for i := 0; i < 0x400; i++ {
+		b.SetMemory(TILEMAP1+types.Word(i), []byte{3})
+	}
+	g.Write(WX, 7+80)
+	assert.Nil(g.LayerImage(LayerBG))
+	g.EnableLayerBuffers(true)
+	g.Step(CyclePerLine)
+
+	bg, window, sprites := g.LayerImage(LayerBG), g.LayerImage(LayerWindow), g.LayerImage(LayerSprites)
+	// Sprite 0 is behind BG colour 0 and drawn.
+	assert.Equal(g.palettes.OBP0[3].R, sprites[0])
+	assert.Equal(byte(0xFF), bg[3])
+	assert.Equal(Source{Layer: LayerSprites, Sprite: 0}, g.Sources()[0])
+	assert.Equal(Source{Layer: LayerSprites, Sprite: 1}, g.Sources()[12])
+	assert.Equal(Source{Layer: LayerBG}, g.Sources()[30])
+	assert.Equal(byte(0), sprites[30*4+3])
+
+	assert.Equal(byte(0), bg[80*4+3])
+	assert.Equal(g.palettes.BG[1].R, window[80*4])
+	assert.Equal(Source{Layer: LayerWindow}, g.Sources()[80])
+
+	g.SetLayerVisible(LayerWindow, false)
+	g.Step(CyclePerLine)
+	assert.Equal(Source{Layer: LayerNone}, g.Sources()[160+80])
+	// Hidden layers are still recorded.
+	assert.Equal(g.palettes.BG[1].R, window[(160+80)*4])
+
+	g.EnableLayerBuffers(false)
+	assert.Nil(g.Sources())
+}
@@ -0,0 +1,292 @@
+package gpu
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image/color"
+	"strconv"
+	"strings"
+)
+
+// Palette is colours of 4 shades from the lightest to the darkest.
+// BGP, OBP0 and OBP1 map colour numbers of pixels to these shades.
+type Palette [4]color.RGBA
+
+// Palettes is a set of palettes for BG and window, and sprites using OBP0 and OBP1.
+type Palettes struct {
+	BG   Palette
+	OBP0 Palette
+	OBP1 Palette
+}
+
+// ErrInvalidPalette means palette data can not be parsed
+var ErrInvalidPalette = errors.New("invalid palette")
+
+func rgb(c uint32) color.RGBA {
+	return color.RGBA{byte(c >> 16), byte(c >> 8), byte(c), 0xFF}
+}
+
+func palette(c0, c1, c2, c3 uint32) Palette {
+	return Palette{rgb(c0), rgb(c1), rgb(c2), rgb(c3)}
+}
+
+func mono(p Palette) Palettes {
+	return Palettes{BG: p, OBP0: p, OBP1: p}
+}
+
+// DefaultPalettes is green shades of DMG.
+var DefaultPalettes = mono(Palette{{175, 197, 160, 255}, {93, 147, 66, 255}, {22, 63, 48, 255}, {0, 40, 0, 255}})
+
+// Built-in palettes in the order listed by PalettePresetNames.
+// cgb-* are the palettes CGB boot ROM selects for DMG cartridges by
+// holding the direction and button during the logo.
+var palettePresets = []struct {
+	name     string
+	palettes Palettes
+}{
+	{"dmg", DefaultPalettes},
+	{"pocket", mono(palette(0xE0DBCD, 0xA89F94, 0x706B66, 0x2B2B26))},
+	{"light", mono(palette(0x00CBB4, 0x00A88F, 0x00785F, 0x00402F))},
+	{"high-contrast", mono(palette(0xFFFFFF, 0xAAAAAA, 0x555555, 0x000000))},
+	{"cgb-up", cgbBoot(5)},
+	{"cgb-up-a", cgbBoot(43)},
+	{"cgb-up-b", cgbBoot(28)},
+	{"cgb-left", cgbBoot(48)},
+	{"cgb-left-a", cgbBoot(40)},
+	{"cgb-left-b", cgbBoot(7)},
+	{"cgb-down", cgbBoot(8)},
+	{"cgb-down-a", cgbBoot(3)},
+	{"cgb-down-b", cgbBoot(49)},
+	{"cgb-right", cgbBoot(1)},
+	{"cgb-right-a", cgbBoot(0)},
+	{"cgb-right-b", cgbBoot(6)},
+}
+
+// cgbBootColors is the colour table of CGB boot ROM in RGB555.
+var cgbBootColors = [...]uint16{
+	0x7FFF, 0x32BF, 0x00D0, 0x0000,
+	0x639F, 0x4279, 0x15B0, 0x04CB,
+	0x7FFF, 0x6E31, 0x454A, 0x0000,
+	0x7FFF, 0x1BEF, 0x0200, 0x0000,
+	0x7FFF, 0x421F, 0x1CF2, 0x0000,
+	0x7FFF, 0x5294, 0x294A, 0x0000,
+	0x7FFF, 0x03FF, 0x012F, 0x0000,
+	0x7FFF, 0x03EF, 0x01D6, 0x0000,
+	0x7FFF, 0x42B5, 0x3DC8, 0x0000,
+	0x7E74, 0x03FF, 0x0180, 0x0000,
+	0x67FF, 0x77AC, 0x1A13, 0x2D6B,
+	0x7ED6, 0x4BFF, 0x2175, 0x0000,
+	0x53FF, 0x4A5F, 0x7E52, 0x0000,
+	0x4FFF, 0x7ED2, 0x3A4C, 0x1CE0,
+	0x03ED, 0x7FFF, 0x255F, 0x0000,
+	0x036A, 0x021F, 0x03FF, 0x7FFF,
+	0x7FFF, 0x01DF, 0x0112, 0x0000,
+	0x231F, 0x035F, 0x00F2, 0x0009,
+	0x7FFF, 0x03EA, 0x011F, 0x0000,
+	0x299F, 0x001A, 0x000C, 0x0000,
+	0x7FFF, 0x027F, 0x001F, 0x0000,
+	0x7FFF, 0x03E0, 0x0206, 0x0120,
+	0x7FFF, 0x7EEB, 0x001F, 0x7C00,
+	0x7FFF, 0x3FFF, 0x7E00, 0x001F,
+	0x7FFF, 0x03FF, 0x001F, 0x0000,
+	0x03FF, 0x001F, 0x000C, 0x0000,
+	0x7FFF, 0x033F, 0x0193, 0x0000,
+	0x0000, 0x4200, 0x037F, 0x7FFF,
+	0x7FFF, 0x7E8C, 0x7C00, 0x0000,
+	0x7FFF, 0x1BEF, 0x6180, 0x0000,
+}
+
+// cgbBootCombinations is the palette combinations of CGB boot ROM.
+// Each is the offsets of OBP0, OBP1 and BG colours in cgbBootColors.
+// Most start at a palette of 4 colours, but a few start in the middle of one.
+var cgbBootCombinations = [...][3]int{
+	{4 * 4, 4 * 4, 29 * 4},
+	{18 * 4, 18 * 4, 18 * 4},
+	{20 * 4, 20 * 4, 20 * 4},
+	{24 * 4, 24 * 4, 24 * 4},
+	{9 * 4, 9 * 4, 9 * 4},
+	{0 * 4, 0 * 4, 0 * 4},
+	{27 * 4, 27 * 4, 27 * 4},
+	{5 * 4, 5 * 4, 5 * 4},
+	{12 * 4, 12 * 4, 12 * 4},
+	{26 * 4, 26 * 4, 26 * 4},
+	{16 * 4, 8 * 4, 8 * 4},
+	{4 * 4, 28 * 4, 28 * 4},
+	{4 * 4, 2 * 4, 2 * 4},
+	{3 * 4, 4 * 4, 4 * 4},
+	{4 * 4, 29 * 4, 29 * 4},
+	{28 * 4, 4 * 4, 28 * 4},
+	{2 * 4, 17 * 4, 2 * 4},
+	{16 * 4, 16 * 4, 8 * 4},
+	{4 * 4, 4 * 4, 7 * 4},
+	{4 * 4, 4 * 4, 18 * 4},
+	{4 * 4, 4 * 4, 20 * 4},
+	{19 * 4, 19 * 4, 9 * 4},
+	{4*4 - 1, 4*4 - 1, 11 * 4},
+	{17 * 4, 17 * 4, 2 * 4},
+	{4 * 4, 4 * 4, 2 * 4},
+	{4 * 4, 4 * 4, 3 * 4},
+	{28 * 4, 28 * 4, 0 * 4},
+	{3 * 4, 3 * 4, 0 * 4},
+	{0 * 4, 0 * 4, 1 * 4},
+	{18 * 4, 22 * 4, 18 * 4},
+	{20 * 4, 22 * 4, 20 * 4},
+	{24 * 4, 22 * 4, 24 * 4},
+	{16 * 4, 22 * 4, 8 * 4},
+	{17 * 4, 4 * 4, 13 * 4},
+	{28*4 - 1, 0 * 4, 14 * 4},
+	{28*4 - 1, 4 * 4, 15 * 4},
+	{19 * 4, 22 * 4, 9 * 4},
+	{16 * 4, 28 * 4, 10 * 4},
+	{4 * 4, 23 * 4, 28 * 4},
+	{17 * 4, 22 * 4, 2 * 4},
+	{4 * 4, 0 * 4, 2 * 4},
+	{4 * 4, 28 * 4, 3 * 4},
+	{28 * 4, 3 * 4, 0 * 4},
+	{3 * 4, 28 * 4, 4 * 4},
+	{21 * 4, 28 * 4, 4 * 4},
+	{3 * 4, 28 * 4, 0 * 4},
+	{25 * 4, 3 * 4, 28 * 4},
+	{0 * 4, 28 * 4, 8 * 4},
+	{4 * 4, 3 * 4, 28 * 4},
+	{28 * 4, 3 * 4, 6 * 4},
+	{4 * 4, 28 * 4, 29 * 4},
+}
+
+// cgbBootPalette converts 4 colours of CGB boot ROM from offset.
+func cgbBootPalette(offset int) Palette {
+	var p Palette
+	for i := range p {
+		v := cgbBootColors[offset+i]
+		p[i] = color.RGBA{R: scale5(v), G: scale5(v >> 5), B: scale5(v >> 10), A: 0xFF}
+	}
+	return p
+}
+
+func cgbBoot(i int) Palettes {
+	c := cgbBootCombinations[i]
+	return Palettes{OBP0: cgbBootPalette(c[0]), OBP1: cgbBootPalette(c[1]), BG: cgbBootPalette(c[2])}
+}
+
+// CGBBootPalettes returns the i-th palette combination CGB boot ROM assigns to DMG games.
+func CGBBootPalettes(i int) (Palettes, bool) {
+	if i < 0 || i >= len(cgbBootCombinations) {
+		return Palettes{}, false
+	}
+	return cgbBoot(i), true
+}
+
+// PalettePreset returns built-in palettes by name.
+func PalettePreset(name string) (Palettes, bool) {
+	for _, p := range palettePresets {
+		if p.name == strings.ToLower(name) {
+			return p.palettes, true
+		}
+	}
+	return Palettes{}, false
+}
+
+// PalettePresetNames returns names of built-in palettes.
+func PalettePresetNames() []string {
+	names := make([]string, len(palettePresets))
+	for i, p := range palettePresets {
+		names[i] = p.name
+	}
+	return names
+}
+
+// SetPalettes sets colours of BG, OBP0 and OBP1 shades.
+// Pixels already drawn keep their colours until the next frame.
+func (g *GPU) SetPalettes(p Palettes) {
+	g.palettes = p
+}
+
+// Palettes returns colours of BG, OBP0 and OBP1 shades.
+func (g *GPU) Palettes() Palettes {
+	return g.palettes
+}
+
+// ParsePalettes parses JSON or JASC-PAL palette data.
+//
+// JSON is an object of "bg", "obp0" and "obp1" arrays of 4 "#RRGGBB" colours,
+// obp0 and obp1 can be omitted to use the colours of bg.
+//
+//	{"bg": ["#FFFFFF", "#AAAAAA", "#555555", "#000000"]}
+//
+// JASC-PAL has 4 colours for all layers or 12 colours of BG, OBP0 and OBP1.
+func ParsePalettes(data []byte) (Palettes, error) {
+	data = bytes.TrimSpace(data)
+	if bytes.HasPrefix(data, []byte("JASC-PAL")) {
+		return parseJASCPalettes(data)
+	}
+	return parseJSONPalettes(data)
+}
+
+func parseJSONPalettes(data []byte) (Palettes, error) {
+	var v struct {
+		BG   []string `json:"bg"`
+		OBP0 []string `json:"obp0"`
+		OBP1 []string `json:"obp1"`
+	}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return Palettes{}, fmt.Errorf("%w: %v", ErrInvalidPalette, err)
+	}
+	if v.OBP0 == nil {
+		v.OBP0 = v.BG
+	}
+	if v.OBP1 == nil {
+		v.OBP1 = v.BG
+	}
+	var p Palettes
+	for _, l := range []struct {
+		dst    *Palette
+		colors []string
+	}{{&p.BG, v.BG}, {&p.OBP0, v.OBP0}, {&p.OBP1, v.OBP1}} {
+		if len(l.colors) != len(l.dst) {
+			return Palettes{}, fmt.Errorf("%w: palette should have 4 colours", ErrInvalidPalette)
+		}
+		for i, s := range l.colors {
+			c, err := strconv.ParseUint(strings.TrimPrefix(s, "#"), 16, 32)
+			if err != nil || len(strings.TrimPrefix(s, "#")) != 6 {
+				return Palettes{}, fmt.Errorf("%w: colour %q", ErrInvalidPalette, s)
+			}
+			l.dst[i] = rgb(uint32(c))
+		}
+	}
+	return p, nil
+}
+
+func parseJASCPalettes(data []byte) (Palettes, error) {
+	var colors []color.RGBA
+	lines := strings.Split(string(data), "\n")
+	if len(lines) < 3 {
+		return Palettes{}, fmt.Errorf("%w: JASC-PAL header is missing", ErrInvalidPalette)
+	}
+	// Skip header, version and number of colours.
+	for _, line := range lines[3:] {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		var r, g, b byte
+		if _, err := fmt.Sscanf(line, "%d %d %d", &r, &g, &b); err != nil {
+			return Palettes{}, fmt.Errorf("%w: colour %q", ErrInvalidPalette, line)
+		}
+		colors = append(colors, color.RGBA{r, g, b, 0xFF})
+	}
+	var p Palettes
+	switch len(colors) {
+	case 4:
+		copy(p.BG[:], colors)
+		p.OBP0, p.OBP1 = p.BG, p.BG
+	case 12:
+		copy(p.BG[:], colors[0:4])
+		copy(p.OBP0[:], colors[4:8])
+		copy(p.OBP1[:], colors[8:12])
+	default:
+		return Palettes{}, fmt.Errorf("%w: JASC-PAL should have 4 or 12 colours", ErrInvalidPalette)
+	}
+	return p, nil
+}
@@ -0,0 +1,94 @@
+package gpu
+
+import (
+	"errors"
+	"image/color"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPalettePreset(t *testing.T) {
+	assert := assert.New(t)
+	for _, name := range PalettePresetNames() {
+		_, ok := PalettePreset(name)
+		assert.True(ok, name)
+	}
+	p, ok := PalettePreset("High-Contrast")
+	assert.True(ok)
+	assert.Equal(color.RGBA{0xAA, 0xAA, 0xAA, 0xFF}, p.OBP1[1])
+	_, ok = PalettePreset("sepia")
+	assert.False(ok)
+}
+
+func TestCGBBootPalettes(t *testing.T) {
+	assert := assert.New(t)
+	up, _ := PalettePreset("cgb-up")
+	p, ok := CGBBootPalettes(5)
+	assert.True(ok)
+	assert.Equal(up, p)
+	assert.Equal(color.RGBA{0xFF, 0xAD, 0x63, 0xFF}, p.BG[1])
+	// Sprite palettes of TENNIS start at the last colour of a palette.
+	p, _ = CGBBootPalettes(34)
+	assert.Equal(color.RGBA{0x6B, 0xFF, 0x00, 0xFF}, p.BG[0])
+	assert.Equal(color.RGBA{0xFF, 0xFF, 0xFF, 0xFF}, p.OBP0[1])
+	assert.Equal(color.RGBA{0x63, 0xA5, 0xFF, 0xFF}, p.OBP0[2])
+	_, ok = CGBBootPalettes(len(cgbBootCombinations))
+	assert.False(ok)
+}
+
+func TestParsePalettesJSON(t *testing.T) {
+	assert := assert.New(t)
+	p, err := ParsePalettes([]byte(`{
+		"bg": ["#FFFFFF", "#AAAAAA", "#555555", "#000000"],
+		"obp1": ["#FF0000", "#00FF00", "#0000FF", "#000000"]
+	}`))
+	assert.NoError(err)
+	assert.Equal(color.RGBA{0x55, 0x55, 0x55, 0xFF}, p.BG[2])
+	assert.Equal(p.BG, p.OBP0)
+	assert.Equal(color.RGBA{0x00, 0xFF, 0x00, 0xFF}, p.OBP1[1])
+
+	for _, data := range []string{
+		`{"bg": ["#FFFFFF", "#AAAAAA", "#555555"]}`,
+		`{"bg": ["#FFFFFF", "#AAAAAA", "#555555", "black"]}`,
+		`{"bg": `,
+	} {
+		_, err := ParsePalettes([]byte(data))
+		assert.True(errors.Is(err, ErrInvalidPalette), data)
+	}
+}
+
+func TestParsePalettesJASC(t *testing.T) {
+	assert := assert.New(t)
+	p, err := ParsePalettes([]byte("JASC-PAL\r\n0100\r\n4\r\n255 255 255\r\n170 170 170\r\n85 85 85\r\n0 0 0\r\n"))
+	assert.NoError(err)
+	assert.Equal(color.RGBA{0xAA, 0xAA, 0xAA, 0xFF}, p.BG[1])
+	assert.Equal(p.BG, p.OBP1)
+
+	data := "JASC-PAL\n0100\n12\n"
+	for i := 0; i < 11; i++ {
+		data += "0 0 0\n"
+	}
+	data += "1 2 3\n"
+	p, err = ParsePalettes([]byte(data))
+	assert.NoError(err)
+	assert.Equal(color.RGBA{1, 2, 3, 0xFF}, p.OBP1[3])
+
+	_, err = ParsePalettes([]byte("JASC-PAL\n0100\n2\n0 0 0\n1 1 1\n"))
+	assert.True(errors.Is(err, ErrInvalidPalette))
+}
+
+func TestSetPalettes(t *testing.T) {
+	assert := assert.New(t)
+	// Sprite of colour 3 with OBP1 on colour 0 of BG.
+	g, _ := setupSprites(0x93, 16, 8, 1, 0x10)
+	g.Write(OBP1, 0xE4)
+	p := DefaultPalettes
+	p.OBP1[3] = color.RGBA{0xFF, 0x00, 0x00, 0xFF}
+	g.SetPalettes(p)
+	assert.Equal(p, g.Palettes())
+	g.Step(CyclePerLine)
+	img := g.GetImageData()
+	assert.Equal([]byte{0xFF, 0x00, 0x00, 0xFF}, img[0:4])
+	assert.Equal([]byte{p.BG[0].R, p.BG[0].G, p.BG[0].B, 0xFF}, img[8*4:8*4+4])
+}
@@ -9,4 +9,17 @@ type Accessor interface {
 
 	ReadByte(addr types.Word) byte
 	ReadWord(addr types.Word) types.Word
+	// Peek reads a byte without side effects of CPU accesses.
+	Peek(addr types.Word) byte
+}
+
+// VideoAccessor is PPU side accessor to VRAM and OAM.
+// PPU fetches bypass CPU side effects such as watchpoints
+// and access blocking by PPU mode.
+type VideoAccessor interface {
+	Accessor
+	ReadVRAM(addr types.Word) byte
+	// ReadVRAMBank reads VRAM bank 0 or 1, bank 1 exists only on CGB.
+	ReadVRAMBank(addr types.Word, bank byte) byte
+	ReadOAM(addr types.Word) byte
 }
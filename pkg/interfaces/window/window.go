@@ -7,4 +7,6 @@ type Window interface {
 	PollKey()
 	KeyDown(button byte)
 	KeyUp(button byte)
+	// Closed reports whether the user closed the window.
+	Closed() bool
 }
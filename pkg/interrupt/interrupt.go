@@ -89,6 +89,13 @@ func NewInterrupt() *Interrupt {
 	}
 }
 
+// PowerOn clears IF, IE and IME to power-on state.
+func (irq *Interrupt) PowerOn() {
+	irq.IF = 0x00
+	irq.IE = 0x00
+	irq.enabled = false
+}
+
 // SetIRQ set flag
 func (irq *Interrupt) SetIRQ(f IRQFlag) {
 	irq.IF |= f
@@ -4,20 +4,38 @@ import (
 	"github.com/bokuweb/gopher-boy/pkg/types"
 )
 
+// MockAccess is a bus access recorded by MockBus
+type MockAccess struct {
+	Addr  types.Word
+	Data  byte
+	Write bool
+}
+
 type MockBus struct {
 	MockMemory [0x10000]byte
+	// MockVRAM1 is CGB VRAM bank 1 at 0x8000-0x9FFF
+	MockVRAM1 [0x2000]byte
+	// Record enables recording Accesses
+	Record   bool
+	Accesses []MockAccess
 }
 
 func (b *MockBus) WriteByte(addr types.Word, data byte) {
+	if b.Record {
+		b.Accesses = append(b.Accesses, MockAccess{addr, data, true})
+	}
 	b.MockMemory[addr] = data
 }
 
 func (b *MockBus) WriteWord(addr types.Word, data types.Word) {
-	b.MockMemory[addr] = byte(data & 0xFF)
-	b.MockMemory[addr+1] = byte(data >> 8)
+	b.WriteByte(addr, byte(data&0xFF))
+	b.WriteByte(addr+1, byte(data>>8))
 }
 
 func (b *MockBus) ReadByte(addr types.Word) byte {
+	if b.Record {
+		b.Accesses = append(b.Accesses, MockAccess{addr, b.MockMemory[addr], false})
+	}
 	return b.MockMemory[addr]
 }
 
@@ -26,8 +44,27 @@ func (b *MockBus) ReadWord(addr types.Word) types.Word {
 	return upper + types.Word(b.MockMemory[addr+1])
 }
 
+func (b *MockBus) Peek(addr types.Word) byte {
+	return b.MockMemory[addr]
+}
+
 func (b *MockBus) SetMemory(offset types.Word, data []byte) {
 	for i, d := range data {
 		b.MockMemory[offset+types.Word(i)] = d
 	}
 }
+
+func (b *MockBus) ReadVRAM(addr types.Word) byte {
+	return b.MockMemory[addr]
+}
+
+func (b *MockBus) ReadVRAMBank(addr types.Word, bank byte) byte {
+	if bank == 1 {
+		return b.MockVRAM1[addr-0x8000]
+	}
+	return b.MockMemory[addr]
+}
+
+func (b *MockBus) ReadOAM(addr types.Word) byte {
+	return b.MockMemory[addr]
+}
@@ -25,6 +25,13 @@ func (r *RAM) Write(addr types.Word, data byte) {
 	r.data[addr] = data
 }
 
+// Clear fills the RAM with 0.
+func (r *RAM) Clear() {
+	for i := range r.data {
+		r.data[i] = 0
+	}
+}
+
 // Debugging
 func (r *RAM) GetBuf() []byte {
 	return r.data
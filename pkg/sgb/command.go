@@ -0,0 +1,217 @@
+package sgb
+
+// SGB commands, sound and SNES program commands are ignored.
+// https://gbdev.io/pandocs/SGB_Command_Summary.html
+const (
+	cmdPAL01   byte = 0x00
+	cmdPAL23   byte = 0x01
+	cmdPAL03   byte = 0x02
+	cmdPAL12   byte = 0x03
+	cmdATTRBLK byte = 0x04
+	cmdATTRLIN byte = 0x05
+	cmdATTRDIV byte = 0x06
+	cmdATTRCHR byte = 0x07
+	cmdPALSET  byte = 0x0A
+	cmdPALTRN  byte = 0x0B
+	cmdMLTREQ  byte = 0x11
+	cmdCHRTRN  byte = 0x13
+	cmdPCTTRN  byte = 0x14
+	cmdATTRTRN byte = 0x15
+	cmdATTRSET byte = 0x16
+	cmdMASKEN  byte = 0x17
+)
+
+// MASK_EN modes
+const (
+	maskCancel byte = iota
+	// maskFreeze keeps the last GB screen
+	maskFreeze
+	maskBlack
+	// maskColor0 fills GB screen with colour 0
+	maskColor0
+)
+
+const (
+	sysPaletteNum = 512
+	// atfNum is number of attribute files ATTR_TRN sends.
+	atfNum  = 45
+	atfSize = cellsX * cellsY / 4
+)
+
+func (s *SGB) command(data []byte) {
+	switch data[0] >> 3 {
+	case cmdPAL01:
+		s.setPalettes(0, 1, data)
+	case cmdPAL23:
+		s.setPalettes(2, 3, data)
+	case cmdPAL03:
+		s.setPalettes(0, 3, data)
+	case cmdPAL12:
+		s.setPalettes(1, 2, data)
+	case cmdATTRBLK:
+		s.attrBlock(data)
+	case cmdATTRLIN:
+		s.attrLine(data)
+	case cmdATTRDIV:
+		s.attrDivide(data)
+	case cmdATTRCHR:
+		s.attrChar(data)
+	case cmdPALSET:
+		s.setSysPalettes(data)
+	case cmdPALTRN, cmdCHRTRN, cmdPCTTRN, cmdATTRTRN:
+		s.transfer = append([]byte(nil), data[:packetSize]...)
+	case cmdMLTREQ:
+		switch data[1] & 0x03 {
+		case 0x01:
+			s.players = 2
+		case 0x03:
+			s.players = 4
+		default:
+			s.players = 1
+		}
+		s.player = 0
+	case cmdATTRSET:
+		s.setATF(data[1])
+	case cmdMASKEN:
+		s.mask = data[1] & 0x03
+	}
+}
+
+// setPalettes sets colour 1-3 of palette p and q. Colour 0 is shared by all palettes.
+func (s *SGB) setPalettes(p, q int, data []byte) {
+	c0 := color555(data[1], data[2])
+	for i := range s.palettes {
+		s.palettes[i][0] = c0
+	}
+	for i := 0; i < 3; i++ {
+		s.palettes[p][i+1] = color555(data[3+i*2], data[4+i*2])
+		s.palettes[q][i+1] = color555(data[9+i*2], data[10+i*2])
+	}
+}
+
+func (s *SGB) fillAttrs(pal byte, in func(x, y int) bool) {
+	for y := 0; y < cellsY; y++ {
+		for x := 0; x < cellsX; x++ {
+			if in(x, y) {
+				s.attrs[y*cellsX+x] = pal
+			}
+		}
+	}
+}
+
+// attrBlock sets palettes inside, on the border of and outside blocks.
+// If only inside or outside is selected, the border also takes its palette.
+func (s *SGB) attrBlock(data []byte) {
+	n := int(data[1])
+	for i := 0; i < n && 2+(i+1)*6 <= len(data); i++ {
+		d := data[2+i*6:]
+		ctrl := d[0] & 0x07
+		inside, border, outside := d[1]&0x03, d[1]>>2&0x03, d[1]>>4&0x03
+		x1, y1, x2, y2 := int(d[2]&0x1F), int(d[3]&0x1F), int(d[4]&0x1F), int(d[5]&0x1F)
+		switch ctrl {
+		case 0x01:
+			border = inside
+		case 0x04:
+			border = outside
+		}
+		s.fillAttrs(inside, func(x, y int) bool {
+			return ctrl&0x01 != 0 && x > x1 && x < x2 && y > y1 && y < y2
+		})
+		s.fillAttrs(border, func(x, y int) bool {
+			inBox := x >= x1 && x <= x2 && y >= y1 && y <= y2
+			onBorder := inBox && (x == x1 || x == x2 || y == y1 || y == y2)
+			return (ctrl&0x02 != 0 || ctrl == 0x01 || ctrl == 0x04) && onBorder
+		})
+		s.fillAttrs(outside, func(x, y int) bool {
+			return ctrl&0x04 != 0 && (x < x1 || x > x2 || y < y1 || y > y2)
+		})
+	}
+}
+
+// attrLine sets palettes of rows or columns of cells.
+func (s *SGB) attrLine(data []byte) {
+	n := int(data[1])
+	for i := 0; i < n && 2+i < len(data); i++ {
+		d := data[2+i]
+		line, pal := int(d&0x1F), d>>5&0x03
+		s.fillAttrs(pal, func(x, y int) bool {
+			if d&0x80 != 0 {
+				return y == line
+			}
+			return x == line
+		})
+	}
+}
+
+// attrDivide divides the screen at a column or row of cells.
+func (s *SGB) attrDivide(data []byte) {
+	d := data[1]
+	pos := int(data[2] & 0x1F)
+	after, before, line := d&0x03, d>>2&0x03, d>>4&0x03
+	for y := 0; y < cellsY; y++ {
+		for x := 0; x < cellsX; x++ {
+			v := x
+			if d&0x40 != 0 {
+				v = y
+			}
+			switch {
+			case v < pos:
+				s.attrs[y*cellsX+x] = before
+			case v == pos:
+				s.attrs[y*cellsX+x] = line
+			default:
+				s.attrs[y*cellsX+x] = after
+			}
+		}
+	}
+}
+
+// attrChar sets palettes of cells one by one from (x, y), 4 cells per byte from upper bits.
+func (s *SGB) attrChar(data []byte) {
+	x, y := int(data[1]&0x1F), int(data[2]&0x1F)
+	n := int(data[3]) | int(data[4])<<8
+	vertical := data[5]&0x01 != 0
+	for i := 0; i < n && 6+i/4 < len(data); i++ {
+		if x >= cellsX || y >= cellsY {
+			return
+		}
+		s.attrs[y*cellsX+x] = data[6+i/4] >> uint(6-i%4*2) & 0x03
+		if vertical {
+			if y++; y == cellsY {
+				y = 0
+				x++
+			}
+		} else {
+			if x++; x == cellsX {
+				x = 0
+				y++
+			}
+		}
+	}
+}
+
+// setSysPalettes selects 4 of the palettes sent by PAL_TRN.
+// Colour 0 of the first palette is used for all palettes.
+func (s *SGB) setSysPalettes(data []byte) {
+	for i := range s.palettes {
+		n := (int(data[1+i*2]) | int(data[2+i*2])<<8) % sysPaletteNum
+		s.palettes[i] = s.sysPalettes[n]
+		s.palettes[i][0] = s.palettes[0][0]
+	}
+	// Bit 7 applies the attribute file, and bit 6 cancels the mask without it.
+	if data[9]&0x80 != 0 {
+		s.setATF(data[9])
+	} else if data[9]&0x40 != 0 {
+		s.mask = maskCancel
+	}
+}
+
+// setATF applies the attribute file of bit 0-5 and bit 6 cancels the mask.
+func (s *SGB) setATF(data byte) {
+	if n := int(data & 0x3F); n < atfNum {
+		s.attrs = s.atfs[n]
+	}
+	if data&0x40 != 0 {
+		s.mask = maskCancel
+	}
+}
@@ -0,0 +1,158 @@
+package sgb
+
+import (
+	"image/color"
+
+	"github.com/bokuweb/gopher-boy/pkg/constants"
+	"github.com/bokuweb/gopher-boy/pkg/gpu"
+	"github.com/bokuweb/gopher-boy/pkg/types"
+)
+
+const (
+	// transferSize is bytes SGB receives from GB screen in VRAM transfer.
+	transferSize = 0x1000
+	// Border tiles are SNES 4bpp tiles sent by two CHR_TRN.
+	borderTileNum = 256
+	tileSize      = 32
+	// PCT_TRN sends 32x32 map and palettes 4-7, the top 28 rows are shown.
+	borderMapSize      = 32 * 32
+	borderPaletteStart = 0x800
+)
+
+// VRAM is GB VRAM which SGB reads for VRAM transfer.
+type VRAM interface {
+	ReadVRAM(addr types.Word) byte
+}
+
+// Transfer receives data of the last *_TRN command.
+// The game shows the data as BG tiles, 20 tiles per row from the top left,
+// and SGB reads 256 tiles of it. lcdc selects BG map and tile data.
+func (s *SGB) Transfer(vram VRAM, lcdc byte) {
+	if s.transfer == nil {
+		return
+	}
+	cmd := s.transfer
+	s.transfer = nil
+	buf := readScreenTiles(vram, lcdc)
+	switch cmd[0] >> 3 {
+	case cmdPALTRN:
+		for i := range s.sysPalettes {
+			for c := 0; c < 4; c++ {
+				s.sysPalettes[i][c] = color555(buf[i*8+c*2], buf[i*8+c*2+1])
+			}
+		}
+	case cmdCHRTRN:
+		base := int(cmd[1]&0x01) * borderTileNum / 2
+		for i := 0; i < borderTileNum/2; i++ {
+			copy(s.tiles[base+i][:], buf[i*tileSize:])
+		}
+	case cmdPCTTRN:
+		for i := range s.borderMap {
+			s.borderMap[i] = uint16(buf[i*2]) | uint16(buf[i*2+1])<<8
+		}
+		for p := range s.borderPalettes {
+			for c := 0; c < 16; c++ {
+				i := borderPaletteStart + p*32 + c*2
+				s.borderPalettes[p][c] = color555(buf[i], buf[i+1])
+			}
+		}
+	case cmdATTRTRN:
+		for n := range s.atfs {
+			for i := range s.atfs[n] {
+				s.atfs[n][i] = buf[n*atfSize+i/4] >> uint(6-i%4*2) & 0x03
+			}
+		}
+	}
+}
+
+func readScreenTiles(vram VRAM, lcdc byte) []byte {
+	buf := make([]byte, transferSize)
+	mapAddr := types.Word(0x9800)
+	if lcdc&0x08 != 0 {
+		mapAddr = 0x9C00
+	}
+	for i := 0; i < transferSize/16; i++ {
+		tile := vram.ReadVRAM(mapAddr + types.Word(i/cellsX*32+i%cellsX))
+		addr := 0x8000 + types.Word(tile)*16
+		if lcdc&0x10 == 0 {
+			addr = types.Word(0x9000 + int(int8(tile))*16)
+		}
+		for j := 0; j < 16; j++ {
+			buf[i*16+j] = vram.ReadVRAM(addr + types.Word(j))
+		}
+	}
+	return buf
+}
+
+// Render returns 256x224 RGBA image of the border and GB screen colourised
+// by the attributes. screen is the indexed image of GB, whose shades are colourised.
+func (s *SGB) Render(screen []gpu.IndexedPixel) []byte {
+	s.renderBorder()
+	switch s.mask {
+	case maskFreeze:
+	case maskBlack:
+		s.fillScreen(color.RGBA{A: 0xFF})
+	case maskColor0:
+		s.fillScreen(s.palettes[0][0])
+	default:
+		for y := 0; y < constants.ScreenHeight; y++ {
+			for x := 0; x < constants.ScreenWidth; x++ {
+				pal := s.attrs[y/8*cellsX+x/8]
+				s.setPixel(screenX+x, screenY+y, s.palettes[pal][screen[y*constants.ScreenWidth+x].Shade()])
+			}
+		}
+	}
+	return s.image
+}
+
+func (s *SGB) fillScreen(c color.RGBA) {
+	for y := 0; y < constants.ScreenHeight; y++ {
+		for x := 0; x < constants.ScreenWidth; x++ {
+			s.setPixel(screenX+x, screenY+y, c)
+		}
+	}
+}
+
+// renderBorder draws the border except GB screen area.
+// Colour 0 of border tiles is transparent and shows colour 0 of palette 0.
+func (s *SGB) renderBorder() {
+	for y := 0; y < Height; y++ {
+		for x := 0; x < Width; x++ {
+			if x >= screenX && x < screenX+constants.ScreenWidth && y >= screenY && y < screenY+constants.ScreenHeight {
+				continue
+			}
+			s.setPixel(x, y, s.borderPixel(x, y))
+		}
+	}
+}
+
+// borderPixel returns colour of the border at (x, y).
+// Map entry has tile number in bit 0-7, palette in bit 10-12 and flips in bit 14-15.
+func (s *SGB) borderPixel(x, y int) color.RGBA {
+	e := s.borderMap[y/8*32+x/8]
+	tile := &s.tiles[e&0xFF]
+	row, col := y%8, x%8
+	if e&0x8000 != 0 {
+		row = 7 - row
+	}
+	if e&0x4000 == 0 {
+		col = 7 - col
+	}
+	bit := uint(col)
+	c := tile[row*2]>>bit&0x01 |
+		tile[row*2+1]>>bit&0x01<<1 |
+		tile[16+row*2]>>bit&0x01<<2 |
+		tile[16+row*2+1]>>bit&0x01<<3
+	if c == 0 {
+		return s.palettes[0][0]
+	}
+	return s.borderPalettes[(e>>10)&0x03][c]
+}
+
+func (s *SGB) setPixel(x, y int, c color.RGBA) {
+	base := (y*Width + x) * 4
+	s.image[base] = c.R
+	s.image[base+1] = c.G
+	s.image[base+2] = c.B
+	s.image[base+3] = c.A
+}
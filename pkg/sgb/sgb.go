@@ -0,0 +1,190 @@
+package sgb
+
+import (
+	"image/color"
+
+	"github.com/bokuweb/gopher-boy/pkg/constants"
+	"github.com/bokuweb/gopher-boy/pkg/pad"
+)
+
+const (
+	// Width is SGB output width with the border.
+	Width = 256
+	// Height is SGB output height with the border.
+	Height = 224
+	// GB screen is placed at the center of the border.
+	screenX = (Width - constants.ScreenWidth) / 2
+	screenY = (Height - constants.ScreenHeight) / 2
+
+	packetSize = 16
+	maxPlayers = 4
+	// Attributes select a palette for each 8x8 cell of GB screen.
+	cellsX = constants.ScreenWidth / 8
+	cellsY = constants.ScreenHeight / 8
+)
+
+// SGB is Super Game Boy, which receives command packets through the joypad register.
+// It colourises GB screen with 4 palettes per 8x8 cell and draws the border around it.
+// SGB wraps the pad of player 1 and is used as the pad of bus.
+type SGB struct {
+	pads    [maxPlayers]*pad.Pad
+	players int
+	player  int
+	// p1 is P14 and P15 of the last write
+	p1 byte
+	// enabled is true when the cartridge supports SGB functions
+	enabled bool
+
+	// receiving is true after reset pulse until the stop bit
+	receiving bool
+	bits      int
+	packet    [packetSize]byte
+	// data is packets of the command and remaining is number of packets to receive
+	data      []byte
+	remaining int
+
+	palettes    [4][4]color.RGBA
+	sysPalettes [sysPaletteNum][4]color.RGBA
+	attrs       [cellsX * cellsY]byte
+	atfs        [atfNum][cellsX * cellsY]byte
+	mask        byte
+	// transfer is VRAM transfer command waiting for the next frame
+	transfer []byte
+
+	tiles          [borderTileNum][tileSize]byte
+	borderMap      [borderMapSize]uint16
+	borderPalettes [4][16]color.RGBA
+	image          []byte
+}
+
+// defaultPalette is the palette SGB uses until the game sets one.
+var defaultPalette = [4]color.RGBA{
+	{R: 0xF7, G: 0xE7, B: 0xC6, A: 0xFF},
+	{R: 0xD6, G: 0x8E, B: 0x49, A: 0xFF},
+	{R: 0xA6, G: 0x37, B: 0x25, A: 0xFF},
+	{R: 0x33, G: 0x1E, B: 0x50, A: 0xFF},
+}
+
+// NewSGB is SGB constructor, p is the pad of player 1.
+func NewSGB(p *pad.Pad) *SGB {
+	s := &SGB{
+		players: 1,
+		p1:      0x30,
+		image:   make([]byte, Width*Height*4),
+	}
+	s.pads[0] = p
+	for i := 1; i < maxPlayers; i++ {
+		s.pads[i] = pad.NewPad()
+	}
+	for i := range s.palettes {
+		s.palettes[i] = defaultPalette
+	}
+	return s
+}
+
+// SetEnabled enables command packets.
+// SGB BIOS accepts packets only from cartridges with SGB flag.
+func (s *SGB) SetEnabled(enabled bool) {
+	s.enabled = enabled
+}
+
+// Pad returns the pad of player n from 0.
+// Pads of player 2-4 are read after the game requests multiplayer with MLT_REQ.
+func (s *SGB) Pad(n int) *pad.Pad {
+	return s.pads[n]
+}
+
+// Players returns number of players requested by MLT_REQ.
+func (s *SGB) Players() int {
+	return s.players
+}
+
+// Press presses the button of player 1.
+func (s *SGB) Press(button pad.Button) {
+	s.pads[0].Press(button)
+}
+
+// Release releases the button of player 1.
+func (s *SGB) Release(button pad.Button) {
+	s.pads[0].Release(button)
+}
+
+// Read reads the joypad of current player.
+// In multiplayer mode, lower 2 bits are inverted player number while P14 and P15 are high.
+func (s *SGB) Read() byte {
+	if s.players > 1 && s.p1 == 0x30 {
+		return 0x30 | (0x0F - byte(s.player))
+	}
+	return s.pads[s.player].Read()
+}
+
+// Write selects P14 and P15 of all pads and receives packet bits.
+// Both low is reset pulse, P14 low is bit 0 and P15 low is bit 1,
+// and each pulse is followed by both high.
+func (s *SGB) Write(data byte) {
+	for _, p := range s.pads {
+		p.Write(data)
+	}
+	prev := s.p1
+	s.p1 = data & 0x30
+	if !s.enabled {
+		return
+	}
+	switch {
+	case s.p1 == 0x00:
+		s.receiving = true
+		s.bits = 0
+		s.packet = [packetSize]byte{}
+	case s.p1 == 0x30:
+		// The next player is selected on rising edge of P15.
+		if !s.receiving && s.players > 1 && prev&0x20 == 0 {
+			s.player = (s.player + 1) % s.players
+		}
+	case prev == 0x30 && s.receiving:
+		s.receiveBit(s.p1 == 0x10)
+	}
+}
+
+// receiveBit receives a bit of the packet from LSB, the packet ends with bit 0.
+func (s *SGB) receiveBit(one bool) {
+	if s.bits == packetSize*8 {
+		s.receiving = false
+		if !one {
+			s.receivePacket()
+		}
+		return
+	}
+	if one {
+		s.packet[s.bits/8] |= 1 << uint(s.bits%8)
+	}
+	s.bits++
+}
+
+// receivePacket collects packets of the command.
+// Lower 3 bits of the first byte are number of packets.
+func (s *SGB) receivePacket() {
+	if s.remaining == 0 {
+		n := int(s.packet[0] & 0x07)
+		if n == 0 {
+			return
+		}
+		s.data = s.data[:0]
+		s.remaining = n
+	}
+	s.data = append(s.data, s.packet[:]...)
+	s.remaining--
+	if s.remaining == 0 {
+		s.command(s.data)
+	}
+}
+
+// color555 converts little endian RGB555 to RGBA.
+func color555(lo, hi byte) color.RGBA {
+	v := uint16(lo) | uint16(hi)<<8
+	return color.RGBA{R: scale5(v), G: scale5(v >> 5), B: scale5(v >> 10), A: 0xFF}
+}
+
+func scale5(v uint16) byte {
+	v &= 0x1F
+	return byte(v<<3 | v>>2)
+}
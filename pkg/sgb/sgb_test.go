@@ -0,0 +1,214 @@
+package sgb
+
+import (
+	"image/color"
+	"testing"
+
+	"github.com/bokuweb/gopher-boy/pkg/constants"
+	"github.com/bokuweb/gopher-boy/pkg/gpu"
+	"github.com/bokuweb/gopher-boy/pkg/mocks"
+	"github.com/bokuweb/gopher-boy/pkg/pad"
+	"github.com/bokuweb/gopher-boy/pkg/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func setup() *SGB {
+	s := NewSGB(pad.NewPad())
+	s.SetEnabled(true)
+	return s
+}
+
+// send sends packets of the command as games do through P14 and P15.
+func send(s *SGB, cmd byte, data ...byte) {
+	n := len(data)/packetSize + 1
+	buf := make([]byte, n*packetSize)
+	buf[0] = cmd<<3 | byte(n)
+	copy(buf[1:], data)
+	for p := 0; p < n; p++ {
+		s.Write(0x00)
+		s.Write(0x30)
+		for i := 0; i < packetSize*8; i++ {
+			if buf[p*packetSize+i/8]>>uint(i%8)&0x01 != 0 {
+				s.Write(0x10)
+			} else {
+				s.Write(0x20)
+			}
+			s.Write(0x30)
+		}
+		s.Write(0x20)
+		s.Write(0x30)
+	}
+}
+
+func pixel(img []byte, x, y int) color.RGBA {
+	base := ((screenY+y)*Width + screenX + x) * 4
+	return color.RGBA{img[base], img[base+1], img[base+2], img[base+3]}
+}
+
+var (
+	white = color.RGBA{0xFF, 0xFF, 0xFF, 0xFF}
+	red   = color.RGBA{0xFF, 0x00, 0x00, 0xFF}
+	green = color.RGBA{0x00, 0xFF, 0x00, 0xFF}
+	blue  = color.RGBA{0x00, 0x00, 0xFF, 0xFF}
+)
+
+// newScreen returns GB screen of colour 0, where BGP maps colour numbers to the same shades.
+func newScreen() []gpu.IndexedPixel {
+	screen := make([]gpu.IndexedPixel, constants.ScreenWidth*constants.ScreenHeight)
+	for i := range screen {
+		screen[i].Value = 0xE4
+	}
+	return screen
+}
+
+func TestPalettes(t *testing.T) {
+	assert := assert.New(t)
+	s := setup()
+	screen := newScreen()
+	screen[1].Color = 1
+	screen[2].Color = 3
+	assert.Equal(defaultPalette[0], pixel(s.Render(screen), 0, 0))
+
+	// PAL01 with white, red, green and blue for palette 0 and 1.
+	send(s, cmdPAL01, 0xFF, 0x7F, 0x1F, 0x00, 0xE0, 0x03, 0x00, 0x7C, 0x1F, 0x00, 0xE0, 0x03, 0x00, 0x7C)
+	img := s.Render(screen)
+	assert.Equal(white, pixel(img, 0, 0))
+	assert.Equal(red, pixel(img, 1, 0))
+	assert.Equal(blue, pixel(img, 2, 0))
+	assert.Equal(white, s.palettes[3][0])
+	assert.Equal(green, s.palettes[1][2])
+}
+
+func TestAttrBlock(t *testing.T) {
+	assert := assert.New(t)
+	s := setup()
+	// Inside palette 1, border palette 2 and outside palette 3 of (2, 2)-(5, 5).
+	send(s, cmdATTRBLK, 1, 0x07, 0x39, 2, 2, 5, 5)
+	assert.Equal(byte(3), s.attrs[0])
+	assert.Equal(byte(2), s.attrs[2*cellsX+2])
+	assert.Equal(byte(1), s.attrs[3*cellsX+3])
+	assert.Equal(byte(2), s.attrs[5*cellsX+4])
+	assert.Equal(byte(3), s.attrs[6*cellsX+6])
+
+	// Only inside is set, so the border also takes the inside palette.
+	send(s, cmdATTRBLK, 1, 0x01, 0x00, 2, 2, 5, 5)
+	assert.Equal(byte(0), s.attrs[2*cellsX+2])
+	assert.Equal(byte(3), s.attrs[0])
+}
+
+func TestAttrLineAndDivide(t *testing.T) {
+	assert := assert.New(t)
+	s := setup()
+	// Vertical line at column 3 with palette 1, horizontal line at row 4 with palette 2.
+	send(s, cmdATTRLIN, 2, 0x23, 0xC4)
+	assert.Equal(byte(1), s.attrs[0*cellsX+3])
+	assert.Equal(byte(2), s.attrs[4*cellsX+0])
+
+	// Horizontal division at row 9, palette 1 above, 2 on and 3 below.
+	send(s, cmdATTRDIV, 0x67, 9)
+	assert.Equal(byte(1), s.attrs[8*cellsX])
+	assert.Equal(byte(2), s.attrs[9*cellsX])
+	assert.Equal(byte(3), s.attrs[10*cellsX])
+
+	send(s, cmdATTRCHR, 19, 0, 3, 0, 0, 0x6C)
+	assert.Equal(byte(1), s.attrs[19])
+	assert.Equal(byte(2), s.attrs[cellsX])
+	assert.Equal(byte(3), s.attrs[cellsX+1])
+}
+
+func TestMultiplayer(t *testing.T) {
+	assert := assert.New(t)
+	s := setup()
+	s.Pad(1).Press(pad.A)
+	assert.Equal(byte(0x3F), s.Read())
+	send(s, cmdMLTREQ, 0x01)
+	assert.Equal(2, s.Players())
+	assert.Equal(byte(0x3F), s.Read())
+	// Reading buttons and releasing P15 selects the next player.
+	s.Write(0x10)
+	s.Write(0x30)
+	assert.Equal(byte(0x3E), s.Read())
+	s.Write(0x10)
+	assert.Equal(byte(0x1E), s.Read())
+	s.Write(0x30)
+	assert.Equal(byte(0x3F), s.Read())
+
+	s.SetEnabled(false)
+	s.Write(0x10)
+	s.Write(0x30)
+	assert.Equal(byte(0x3F), s.Read())
+}
+
+func TestMask(t *testing.T) {
+	assert := assert.New(t)
+	s := setup()
+	screen := newScreen()
+	send(s, cmdMASKEN, maskBlack)
+	assert.Equal(color.RGBA{A: 0xFF}, pixel(s.Render(screen), 0, 0))
+	// Frozen screen keeps the last image.
+	send(s, cmdMASKEN, maskFreeze)
+	screen[0].Color = 3
+	assert.Equal(color.RGBA{A: 0xFF}, pixel(s.Render(screen), 0, 0))
+	send(s, cmdMASKEN, maskCancel)
+	assert.Equal(defaultPalette[3], pixel(s.Render(screen), 0, 0))
+}
+
+// showTransfer puts buf on the screen as 20 tiles per row in 9800 map with 8000 tile data.
+func showTransfer(b *mocks.MockBus, buf []byte) {
+	for i := 0; i < transferSize/16; i++ {
+		b.SetMemory(0x9800+types.Word(i/cellsX*32+i%cellsX), []byte{byte(i)})
+	}
+	b.SetMemory(0x8000, buf)
+}
+
+func TestBorder(t *testing.T) {
+	assert := assert.New(t)
+	s := setup()
+	b := &mocks.MockBus{}
+	// Tile 1 is colour 1 on the first row.
+	tiles := make([]byte, transferSize)
+	tiles[tileSize+0] = 0xFF
+	send(s, cmdCHRTRN, 0x00)
+	showTransfer(b, tiles)
+	s.Transfer(b, 0x91)
+
+	// Map entry 0 is tile 1 with palette 4, whose colour 1 is red.
+	pct := make([]byte, transferSize)
+	pct[0] = 0x01
+	pct[1] = 0x10
+	pct[borderPaletteStart+2] = 0x1F
+	send(s, cmdPCTTRN)
+	showTransfer(b, pct)
+	s.Transfer(b, 0x91)
+
+	img := s.Render(newScreen())
+	assert.Equal([]byte{0xFF, 0x00, 0x00, 0xFF}, img[0:4])
+	// Colour 0 is transparent.
+	assert.Equal([]byte{defaultPalette[0].R, defaultPalette[0].G, defaultPalette[0].B, 0xFF}, img[Width*4:Width*4+4])
+}
+
+func TestPaletteTransfer(t *testing.T) {
+	assert := assert.New(t)
+	s := setup()
+	b := &mocks.MockBus{}
+	buf := make([]byte, transferSize)
+	// Colour 1 of system palette 5 is blue.
+	buf[5*8+2] = 0x00
+	buf[5*8+3] = 0x7C
+	send(s, cmdPALTRN)
+	showTransfer(b, buf)
+	s.Transfer(b, 0x91)
+	send(s, cmdPALSET, 5, 0, 0, 0, 0, 0, 0, 0, 0x00)
+	assert.Equal(blue, s.palettes[0][1])
+}
+
+func TestPalSetCancelMask(t *testing.T) {
+	assert := assert.New(t)
+	s := setup()
+	send(s, cmdMASKEN, maskBlack)
+	send(s, cmdPALSET, 0, 0, 0, 0, 0, 0, 0, 0, 0x00)
+	assert.Equal(maskBlack, s.mask)
+	// Bit 6 cancels the mask without bit 7.
+	send(s, cmdPALSET, 0, 0, 0, 0, 0, 0, 0, 0, 0x40)
+	assert.Equal(maskCancel, s.mask)
+}
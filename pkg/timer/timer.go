@@ -49,6 +49,16 @@ func NewTimer() *Timer {
 	}
 }
 
+// PowerOn resets the counter and registers to power-on state.
+func (timer *Timer) PowerOn() {
+	*timer = Timer{}
+}
+
+// SetCounter sets internal 16bit counter, upper 8bit of which is DIV.
+func (timer *Timer) SetCounter(c uint16) {
+	timer.internalCounter = c
+}
+
 // Update timer counter registers
 // If timer is overflowed return true
 func (timer *Timer) Update(cycles uint) bool {
@@ -82,7 +92,8 @@ func (timer *Timer) Read(addr types.Word) byte {
 	case TMA:
 		return timer.TMA
 	case TAC:
-		return timer.TAC
+		// Upper 5 bits are unused and always read as 1.
+		return timer.TAC | 0xF8
 	}
 	panic("Illegal access detected.")
 }
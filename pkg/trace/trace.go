@@ -0,0 +1,188 @@
+package trace
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Fields are compared in this order.
+var fieldNames = []string{"A", "F", "B", "C", "D", "E", "H", "L", "SP", "PC"}
+
+// Entry is a parsed line of gameboy-doctor style trace log.
+//
+//	A:01 F:B0 B:00 C:13 D:00 E:D8 H:01 L:4D SP:FFFE PC:0100 PCMEM:00,C3,13,02
+type Entry struct {
+	Text   string
+	Regs   [10]uint16
+	HasMem bool
+	Mem    [4]byte
+}
+
+// Parse parses a trace line.
+// Keys and values are case insensitive and PCMEM is optional.
+func Parse(line string) (*Entry, error) {
+	e := &Entry{Text: line}
+	found := 0
+	for _, f := range strings.Fields(line) {
+		kv := strings.SplitN(f, ":", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		key := strings.ToUpper(kv[0])
+		if key == "PCMEM" {
+			mem := strings.Split(kv[1], ",")
+			if len(mem) != len(e.Mem) {
+				return nil, fmt.Errorf("invalid PCMEM %q", kv[1])
+			}
+			for i, m := range mem {
+				v, err := strconv.ParseUint(m, 16, 8)
+				if err != nil {
+					return nil, fmt.Errorf("invalid PCMEM %q", kv[1])
+				}
+				e.Mem[i] = byte(v)
+			}
+			e.HasMem = true
+			continue
+		}
+		for i, name := range fieldNames {
+			if key != name {
+				continue
+			}
+			v, err := strconv.ParseUint(kv[1], 16, 16)
+			if err != nil {
+				return nil, fmt.Errorf("invalid %s value %q", name, kv[1])
+			}
+			e.Regs[i] = uint16(v)
+			found |= 1 << uint(i)
+		}
+	}
+	if found != 1<<uint(len(fieldNames))-1 {
+		return nil, fmt.Errorf("missing registers in %q", line)
+	}
+	return e, nil
+}
+
+// Mismatches returns names of the fields which differ from other.
+// PCMEM is compared only if both entries have it.
+func (e *Entry) Mismatches(other *Entry) []string {
+	var m []string
+	for i, name := range fieldNames {
+		if e.Regs[i] != other.Regs[i] {
+			m = append(m, name)
+		}
+	}
+	if e.HasMem && other.HasMem && e.Mem != other.Mem {
+		m = append(m, "PCMEM")
+	}
+	return m
+}
+
+// Divergence describes the first line where two traces disagree.
+type Divergence struct {
+	// Line is 1 origin line number of the diverged entry.
+	Line int
+	// Context has preceding lines which both traces agree on.
+	Context []string
+	// Got and Want are diverged lines. Empty string means the trace has already ended.
+	Got    string
+	Want   string
+	Fields []string
+}
+
+func (d *Divergence) String() string {
+	var b strings.Builder
+	for i, c := range d.Context {
+		fmt.Fprintf(&b, "  %6d  %s\n", d.Line-len(d.Context)+i, c)
+	}
+	got, want := d.Got, d.Want
+	if got == "" {
+		got = "<end of trace>"
+	}
+	if want == "" {
+		want = "<end of trace>"
+	}
+	fmt.Fprintf(&b, "- %6d  %s\n", d.Line, want)
+	fmt.Fprintf(&b, "+ %6d  %s\n", d.Line, got)
+	if len(d.Fields) > 0 {
+		fmt.Fprintf(&b, "mismatch: %s\n", strings.Join(d.Fields, " "))
+	}
+	return b.String()
+}
+
+// Diff streams got and want traces and returns the first divergence.
+// nil is returned when both traces are identical.
+// context is the number of preceding lines kept in Divergence.
+func Diff(got, want io.Reader, context int) (*Divergence, error) {
+	g := newReader(got)
+	w := newReader(want)
+	history := make([]string, 0, context)
+	line := 0
+	for {
+		ge, gerr := g.next()
+		we, werr := w.next()
+		if gerr != nil && gerr != io.EOF {
+			return nil, gerr
+		}
+		if werr != nil && werr != io.EOF {
+			return nil, werr
+		}
+		if gerr == io.EOF && werr == io.EOF {
+			return nil, nil
+		}
+		line++
+		d := &Divergence{Line: line, Context: history}
+		switch {
+		case gerr == io.EOF:
+			d.Want = we.Text
+			return d, nil
+		case werr == io.EOF:
+			d.Got = ge.Text
+			return d, nil
+		}
+		if m := ge.Mismatches(we); len(m) > 0 {
+			d.Got = ge.Text
+			d.Want = we.Text
+			d.Fields = m
+			return d, nil
+		}
+		if context == 0 {
+			continue
+		}
+		if len(history) == context {
+			history = append(history[:0], history[1:]...)
+		}
+		history = append(history, we.Text)
+	}
+}
+
+type reader struct {
+	s    *bufio.Scanner
+	line int
+}
+
+func newReader(r io.Reader) *reader {
+	return &reader{s: bufio.NewScanner(r)}
+}
+
+// next returns next entry skipping empty lines.
+func (r *reader) next() (*Entry, error) {
+	for r.s.Scan() {
+		r.line++
+		text := strings.TrimSpace(r.s.Text())
+		if text == "" {
+			continue
+		}
+		e, err := Parse(text)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", r.line, err)
+		}
+		return e, nil
+	}
+	if err := r.s.Err(); err != nil {
+		return nil, err
+	}
+	return nil, io.EOF
+}
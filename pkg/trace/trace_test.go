@@ -0,0 +1,66 @@
+package trace
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const line1 = "A:01 F:B0 B:00 C:13 D:00 E:D8 H:01 L:4D SP:FFFE PC:0100 PCMEM:00,C3,13,02"
+const line2 = "A:01 F:B0 B:00 C:13 D:00 E:D8 H:01 L:4D SP:FFFE PC:0101 PCMEM:C3,13,02,CE"
+const line3 = "A:01 F:B0 B:00 C:13 D:00 E:D8 H:01 L:4D SP:FFFE PC:0213 PCMEM:00,00,00,00"
+
+func TestParse(t *testing.T) {
+	assert := assert.New(t)
+	e, err := Parse(strings.ToLower(line1))
+	assert.NoError(err)
+	assert.Equal(uint16(0xFFFE), e.Regs[8])
+	assert.Equal(uint16(0x0100), e.Regs[9])
+	assert.True(e.HasMem)
+	assert.Equal([4]byte{0x00, 0xC3, 0x13, 0x02}, e.Mem)
+
+	_, err = Parse("A:01 F:B0")
+	assert.Error(err)
+}
+
+func TestDiffIdentical(t *testing.T) {
+	assert := assert.New(t)
+	got := strings.Join([]string{line1, line2}, "\n")
+	want := strings.ToLower(strings.Join([]string{line1, line2}, "\n"))
+	d, err := Diff(strings.NewReader(got), strings.NewReader(want), 3)
+	assert.NoError(err)
+	assert.Nil(d)
+}
+
+func TestDiffWithoutPCMEM(t *testing.T) {
+	assert := assert.New(t)
+	want := line1[:strings.Index(line1, " PCMEM")]
+	d, err := Diff(strings.NewReader(line1), strings.NewReader(want), 3)
+	assert.NoError(err)
+	assert.Nil(d)
+}
+
+func TestDiffDiverged(t *testing.T) {
+	assert := assert.New(t)
+	diverged := strings.Replace(line3, "A:01", "A:02", 1)
+	got := strings.Join([]string{line1, line2, line3}, "\n")
+	want := strings.Join([]string{line1, line2, diverged}, "\n")
+	d, err := Diff(strings.NewReader(got), strings.NewReader(want), 1)
+	assert.NoError(err)
+	assert.Equal(3, d.Line)
+	assert.Equal([]string{line2}, d.Context)
+	assert.Equal([]string{"A"}, d.Fields)
+	assert.Equal(line3, d.Got)
+	assert.Equal(diverged, d.Want)
+}
+
+func TestDiffEnded(t *testing.T) {
+	assert := assert.New(t)
+	got := strings.Join([]string{line1, line2}, "\n")
+	d, err := Diff(strings.NewReader(got), strings.NewReader(line1), 3)
+	assert.NoError(err)
+	assert.Equal(2, d.Line)
+	assert.Equal(line2, d.Got)
+	assert.Equal("", d.Want)
+}
@@ -0,0 +1,43 @@
+package viewer
+
+import (
+	"image"
+	"image/png"
+	"os"
+	"path/filepath"
+)
+
+// Dump writes tiles.png, bg0.png, bg1.png, window.png and oam.png to dir.
+func (v *Viewer) Dump(dir string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	images := []struct {
+		name string
+		img  image.Image
+	}{
+		{"tiles.png", v.Tiles()},
+		{"bg0.png", v.BGMap(0)},
+		{"bg1.png", v.BGMap(1)},
+		{"window.png", v.WindowMap()},
+		{"oam.png", v.OAM()},
+	}
+	for _, i := range images {
+		if err := writePNG(filepath.Join(dir, i.name), i.img); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writePNG(path string, img image.Image) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := png.Encode(f, img); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
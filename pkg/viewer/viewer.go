@@ -0,0 +1,182 @@
+package viewer
+
+import (
+	"image"
+	"image/color"
+
+	"github.com/bokuweb/gopher-boy/pkg/constants"
+	"github.com/bokuweb/gopher-boy/pkg/gpu"
+	"github.com/bokuweb/gopher-boy/pkg/interfaces/bus"
+	"github.com/bokuweb/gopher-boy/pkg/types"
+)
+
+const (
+	// TileNum is number of tiles in VRAM bank 0.
+	TileNum = 384
+	// Tile sheet has 16 tiles per row.
+	sheetColumns = 16
+	mapSize      = 32
+	spriteNum    = 40
+	// OAM table has 8 sprites per row, each cell has 8x16 sprite and 1 pixel margin.
+	oamColumns = 8
+	oamCellW   = 8 + 2
+	oamCellH   = 16 + 2
+)
+
+// viewportColor is colour of the visible area drawn over maps.
+var viewportColor = color.RGBA{R: 0x00, G: 0x00, B: 0xFF, A: 0xFF}
+
+// Viewer renders VRAM and OAM as images for debugging.
+// Colours are from BGP, OBP0 and OBP1 with the GPU palettes,
+// and CGB attributes and VRAM bank 1 are not shown.
+type Viewer struct {
+	vram bus.VideoAccessor
+	gpu  *gpu.GPU
+}
+
+// NewViewer is Viewer constructor
+func NewViewer(vram bus.VideoAccessor, g *gpu.GPU) *Viewer {
+	return &Viewer{vram: vram, gpu: g}
+}
+
+// tilePixel returns colour number of the tile at (x, y).
+// Tile n is at 0x8000 + n * 0x10, so 256-383 are tiles 0x80-0xFF of 0x8800 addressing.
+func (v *Viewer) tilePixel(n, x, y int) byte {
+	addr := gpu.TILEDATA1 + types.Word(n*0x10+y*2)
+	low := v.vram.ReadVRAM(addr)
+	high := v.vram.ReadVRAM(addr + 1)
+	bit := uint(7 - x)
+	return (high>>bit)&0x01<<1 | (low>>bit)&0x01
+}
+
+// bgTile returns tile number of the tile ID by LCDC bit 4.
+func (v *Viewer) bgTile(id byte) int {
+	if v.gpu.Read(gpu.LCDC)&0x10 != 0 {
+		return int(id)
+	}
+	return 256 + int(int8(id))
+}
+
+func shade(palette byte, c byte) byte {
+	return (palette >> (c * 2)) & 0x03
+}
+
+// Tiles renders 384 tiles in 16 columns without palette registers.
+func (v *Viewer) Tiles() *image.RGBA {
+	palette := v.gpu.Palettes().BG
+	img := image.NewRGBA(image.Rect(0, 0, sheetColumns*8, TileNum/sheetColumns*8))
+	for n := 0; n < TileNum; n++ {
+		ox, oy := n%sheetColumns*8, n/sheetColumns*8
+		for y := 0; y < 8; y++ {
+			for x := 0; x < 8; x++ {
+				img.SetRGBA(ox+x, oy+y, palette[v.tilePixel(n, x, y)])
+			}
+		}
+	}
+	return img
+}
+
+// renderMap renders 32x32 tiles of the map at addr with BGP.
+func (v *Viewer) renderMap(addr types.Word) *image.RGBA {
+	palette := v.gpu.Palettes().BG
+	bgp := v.gpu.Read(gpu.BGP)
+	img := image.NewRGBA(image.Rect(0, 0, mapSize*8, mapSize*8))
+	for i := 0; i < mapSize*mapSize; i++ {
+		n := v.bgTile(v.vram.ReadVRAM(addr + types.Word(i)))
+		ox, oy := i%mapSize*8, i/mapSize*8
+		for y := 0; y < 8; y++ {
+			for x := 0; x < 8; x++ {
+				img.SetRGBA(ox+x, oy+y, palette[shade(bgp, v.tilePixel(n, x, y))])
+			}
+		}
+	}
+	return img
+}
+
+// BGMap renders the map at 0x9800 (n = 0) or 0x9C00 (n = 1).
+// If LCDC selects the map for BG, the viewport of SCX and SCY is drawn,
+// which wraps around the map.
+func (v *Viewer) BGMap(n int) *image.RGBA {
+	addr := types.Word(gpu.TILEMAP0)
+	if n != 0 {
+		addr = gpu.TILEMAP1
+	}
+	img := v.renderMap(addr)
+	if (v.gpu.Read(gpu.LCDC)&0x08 != 0) == (n != 0) {
+		drawRect(img, int(v.gpu.Read(gpu.SCROLLX)), int(v.gpu.Read(gpu.SCROLLY)), constants.ScreenWidth, constants.ScreenHeight)
+	}
+	return img
+}
+
+// WindowMap renders the map LCDC selects for the window with the part shown on the screen.
+func (v *Viewer) WindowMap() *image.RGBA {
+	addr := types.Word(gpu.TILEMAP0)
+	if v.gpu.Read(gpu.LCDC)&0x40 != 0 {
+		addr = gpu.TILEMAP1
+	}
+	img := v.renderMap(addr)
+	wx, wy := int(v.gpu.Read(gpu.WX))-7, int(v.gpu.Read(gpu.WY))
+	if wx < 0 {
+		wx = 0
+	}
+	if wx < constants.ScreenWidth && wy < constants.ScreenHeight {
+		drawRect(img, 0, 0, constants.ScreenWidth-wx, constants.ScreenHeight-wy)
+	}
+	return img
+}
+
+// OAM renders 40 sprites in OAM order, 8 sprites per row.
+// The second tile is also drawn for 8x16 sprites and colour 0 is transparent.
+func (v *Viewer) OAM() *image.RGBA {
+	palettes := v.gpu.Palettes()
+	lcdc := v.gpu.Read(gpu.LCDC)
+	img := image.NewRGBA(image.Rect(0, 0, oamColumns*oamCellW, spriteNum/oamColumns*oamCellH))
+	for i := 0; i < spriteNum; i++ {
+		base := gpu.OAMSTART + types.Word(i*4)
+		tile := int(v.vram.ReadOAM(base + 2))
+		attr := v.vram.ReadOAM(base + 3)
+		height := 8
+		if lcdc&0x04 != 0 {
+			height = 16
+			tile &= 0xFE
+		}
+		palette, obp := palettes.OBP0, v.gpu.Read(gpu.OBP0)
+		if attr&0x10 != 0 {
+			palette, obp = palettes.OBP1, v.gpu.Read(gpu.OBP1)
+		}
+		ox, oy := i%oamColumns*oamCellW+1, i/oamColumns*oamCellH+1
+		for y := 0; y < height; y++ {
+			for x := 0; x < 8; x++ {
+				tx, ty := x, y
+				if attr&0x20 != 0 {
+					tx = 7 - x
+				}
+				if attr&0x40 != 0 {
+					ty = height - 1 - y
+				}
+				c := v.tilePixel(tile+ty/8, tx, ty%8)
+				if c == 0 {
+					continue
+				}
+				img.SetRGBA(ox+x, oy+y, palette[shade(obp, c)])
+			}
+		}
+	}
+	return img
+}
+
+// drawRect draws outline of the rectangle wrapping around the image.
+func drawRect(img *image.RGBA, x, y, w, h int) {
+	size := img.Bounds().Size()
+	set := func(px, py int) {
+		img.SetRGBA((px%size.X+size.X)%size.X, (py%size.Y+size.Y)%size.Y, viewportColor)
+	}
+	for i := 0; i < w; i++ {
+		set(x+i, y)
+		set(x+i, y+h-1)
+	}
+	for i := 0; i < h; i++ {
+		set(x, y+i)
+		set(x+w-1, y+i)
+	}
+}
@@ -0,0 +1,113 @@
+package viewer
+
+import (
+	"image/color"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/bokuweb/gopher-boy/pkg/gpu"
+	"github.com/bokuweb/gopher-boy/pkg/interrupt"
+	"github.com/bokuweb/gopher-boy/pkg/mocks"
+	"github.com/bokuweb/gopher-boy/pkg/types"
+	"github.com/stretchr/testify/assert"
+)
+
+// setup returns Viewer with tile 1 filled with colour 3 and
+// the left half of tile 0x80 filled with colour 1.
+func setup() (*Viewer, *gpu.GPU, *mocks.MockBus) {
+	b := &mocks.MockBus{}
+	g := gpu.NewGPU()
+	g.Init(b, interrupt.NewInterrupt())
+	g.Write(gpu.BGP, 0xE4)
+	g.Write(gpu.OBP0, 0xE4)
+	for i := 0; i < 16; i += 2 {
+		b.SetMemory(0x8010+types.Word(i), []byte{0xFF, 0xFF})
+		b.SetMemory(0x8800+types.Word(i), []byte{0xF0, 0x00})
+	}
+	return NewViewer(b, g), g, b
+}
+
+func TestTiles(t *testing.T) {
+	assert := assert.New(t)
+	v, g, _ := setup()
+	img := v.Tiles()
+	assert.Equal(128, img.Bounds().Dx())
+	assert.Equal(192, img.Bounds().Dy())
+	bg := g.Palettes().BG
+	assert.Equal(bg[0], img.RGBAAt(0, 0))
+	assert.Equal(bg[3], img.RGBAAt(8, 0))
+	// Tile 0x80 is at row 8.
+	assert.Equal(bg[1], img.RGBAAt(3, 64))
+	assert.Equal(bg[0], img.RGBAAt(4, 64))
+}
+
+func TestBGMap(t *testing.T) {
+	assert := assert.New(t)
+	v, g, b := setup()
+	bg := g.Palettes().BG
+	b.SetMemory(gpu.TILEMAP0+1, []byte{0x01})
+	b.SetMemory(gpu.TILEMAP1+1, []byte{0x80})
+	g.Write(gpu.SCROLLX, 200)
+	g.Write(gpu.SCROLLY, 16)
+	img := v.BGMap(0)
+	assert.Equal(bg[3], img.RGBAAt(9, 0))
+	// Viewport wraps around the map.
+	assert.Equal(viewportColor, img.RGBAAt(200, 16))
+	assert.Equal(viewportColor, img.RGBAAt(100, 16))
+	assert.Equal(viewportColor, img.RGBAAt(103, 159))
+	assert.Equal(bg[0], img.RGBAAt(104, 16))
+
+	// Map 1 is not used for BG, and tile 0x80 is read by 0x8800 addressing.
+	g.Write(gpu.LCDC, 0x81)
+	img = v.BGMap(1)
+	assert.Equal(bg[1], img.RGBAAt(8, 0))
+	assert.Equal(bg[0], img.RGBAAt(200, 16))
+	g.Write(gpu.BGP, 0x1B)
+	assert.Equal(bg[2], v.BGMap(1).RGBAAt(8, 0))
+}
+
+func TestWindowMap(t *testing.T) {
+	assert := assert.New(t)
+	v, g, _ := setup()
+	g.Write(gpu.LCDC, 0xF1)
+	g.Write(gpu.WX, 7+60)
+	g.Write(gpu.WY, 44)
+	img := v.WindowMap()
+	assert.Equal(viewportColor, img.RGBAAt(99, 0))
+	assert.Equal(viewportColor, img.RGBAAt(0, 99))
+	assert.Equal(g.Palettes().BG[0], img.RGBAAt(100, 0))
+}
+
+func TestOAM(t *testing.T) {
+	assert := assert.New(t)
+	v, g, b := setup()
+	obp0 := g.Palettes().OBP0
+	// Sprite 1 is tile 0x80 flipped horizontally.
+	b.SetMemory(gpu.OAMSTART, []byte{16, 8, 1, 0, 16, 8, 0x80, 0x20})
+	img := v.OAM()
+	assert.Equal(obp0[3], img.RGBAAt(1, 1))
+	assert.Equal(color.RGBA{}, img.RGBAAt(1, 9))
+	assert.Equal(color.RGBA{}, img.RGBAAt(oamCellW+1, 1))
+	assert.Equal(obp0[1], img.RGBAAt(oamCellW+5, 1))
+
+	// The second tile of 8x16 sprite is drawn.
+	g.Write(gpu.LCDC, 0x97)
+	b.SetMemory(gpu.OAMSTART, []byte{16, 8, 0, 0})
+	assert.Equal(obp0[3], v.OAM().RGBAAt(1, 9))
+}
+
+func TestDump(t *testing.T) {
+	v, _, _ := setup()
+	dir, err := ioutil.TempDir("", "viewer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	assert.NoError(t, v.Dump(dir))
+	for _, name := range []string{"tiles.png", "bg0.png", "bg1.png", "window.png", "oam.png"} {
+		_, err := os.Stat(filepath.Join(dir, name))
+		assert.NoError(t, err, name)
+	}
+}
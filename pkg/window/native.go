@@ -18,16 +18,42 @@ type Window struct {
 	win   *pixelgl.Window
 	image *pixel.PictureData
 	pad   *pad.Pad
+	// width and height are size of rendered image, GB screen size if 0
+	width  int
+	height int
+	// dump is called when dumpKey is pressed
+	dump func()
+}
+
+// dumpKey dumps VRAM viewers, see SetDumpHandler.
+const dumpKey = pixelgl.KeyF12
+
+// SetScreenSize sets size of rendered image, such as SGB output with the border.
+func (w *Window) SetScreenSize(width, height int) {
+	w.width = width
+	w.height = height
+}
+
+// SetDumpHandler sets f called when F12 is pressed.
+func (w *Window) SetDumpHandler(f func()) {
+	w.dump = f
+}
+
+func (w *Window) size() (int, int) {
+	if w.width == 0 {
+		return constants.ScreenWidth, constants.ScreenHeight
+	}
+	return w.width, w.height
 }
 
 // Render renders the pixels on the window.
 func (w *Window) Render(buf []byte) {
-
-	imgData := make([]color.RGBA, constants.ScreenWidth*constants.ScreenHeight)
+	width, height := w.size()
+	imgData := make([]color.RGBA, width*height)
 	i := 0
 	for i*4 < len(buf) {
-		y := constants.ScreenHeight - (i / constants.ScreenWidth) - 1
-		imgData[y*constants.ScreenWidth+i%constants.ScreenWidth] = color.RGBA{buf[i*4], buf[i*4+1], buf[i*4+2], buf[i*4+3]}
+		y := height - (i / width) - 1
+		imgData[y*width+i%width] = color.RGBA{buf[i*4], buf[i*4+1], buf[i*4+2], buf[i*4+3]}
 		i++
 	}
 
@@ -36,7 +62,7 @@ func (w *Window) Render(buf []byte) {
 	bg := color.RGBA{R: 0x0F, G: 0x38, B: 0x0F, A: 0xFF}
 	w.win.Clear(bg)
 
-	spr := pixel.NewSprite(pixel.Picture(w.image), pixel.R(0, 0, constants.ScreenWidth, constants.ScreenHeight))
+	spr := pixel.NewSprite(pixel.Picture(w.image), pixel.R(0, 0, float64(width), float64(height)))
 	spr.Draw(w.win, pixel.IM)
 	w.updateCamera()
 	w.win.Update()
@@ -47,8 +73,9 @@ func (w *Window) Run(f func()) {
 }
 
 func (w *Window) updateCamera() {
-	xScale := w.win.Bounds().W() / constants.ScreenWidth
-	yScale := w.win.Bounds().H() / constants.ScreenHeight
+	width, height := w.size()
+	xScale := w.win.Bounds().W() / float64(width)
+	yScale := w.win.Bounds().H() / float64(height)
 	scale := math.Min(yScale, xScale)
 
 	shift := w.win.Bounds().Size().Scaled(0.5).Sub(pixel.ZV)
@@ -57,9 +84,10 @@ func (w *Window) updateCamera() {
 }
 
 func (w *Window) Init() {
+	width, height := w.size()
 	cfg := pixelgl.WindowConfig{
 		Title:  "gopher-boy",
-		Bounds: pixel.R(0, 0, constants.ScreenWidth, constants.ScreenHeight),
+		Bounds: pixel.R(0, 0, float64(width), float64(height)),
 	}
 	win, err := pixelgl.NewWindow(cfg)
 	if err != nil {
@@ -68,9 +96,9 @@ func (w *Window) Init() {
 	win.Clear(colornames.Skyblue)
 	w.win = win
 	w.image = &pixel.PictureData{
-		Pix:    make([]color.RGBA, constants.ScreenWidth*constants.ScreenHeight),
-		Stride: constants.ScreenWidth,
-		Rect:   pixel.R(0, 0, constants.ScreenWidth, constants.ScreenHeight),
+		Pix:    make([]color.RGBA, width*height),
+		Stride: width,
+		Rect:   pixel.R(0, 0, float64(width), float64(height)),
 	}
 
 	// Hack: https://github.com/faiface/pixel/issues/140
@@ -82,6 +110,9 @@ func (w *Window) Init() {
 }
 
 func (w *Window) PollKey() {
+	if w.dump != nil && w.win.JustPressed(dumpKey) {
+		w.dump()
+	}
 	for key, button := range keyMap {
 		if w.win.JustPressed(key) {
 			w.pad.Press(button)
@@ -92,6 +123,11 @@ func (w *Window) PollKey() {
 	}
 }
 
+// Closed reports whether the window is closed by the user.
+func (w *Window) Closed() bool {
+	return w.win.Closed()
+}
+
 func (w *Window) KeyDown(button byte) {
 	/* NOP */
 }
@@ -25,6 +25,21 @@ func (w *Window) Init() {
 	/* NOP */
 }
 
+// SetScreenSize sets size of rendered image.
+func (w *Window) SetScreenSize(width, height int) {
+	/* NOP */
+}
+
+// SetDumpHandler sets f called by the hotkey, which the browser does not have.
+func (w *Window) SetDumpHandler(f func()) {
+	/* NOP */
+}
+
+// Closed reports false, the browser stops the emulator by itself.
+func (w *Window) Closed() bool {
+	return false
+}
+
 func (w *Window) PollKey() {
 	i := byte(0)
 	for i < 8 {